@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultDedupeWindow is used when DEDUPE_WINDOW is unset. Graph retries
+// undelivered webhooks for a while, so this spans several retries.
+const defaultDedupeWindow = 10 * time.Minute
+
+// messageIDs remembers recently handled message IDs so webhook retries
+// are answered only once
+type messageIDs struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	swept  time.Time
+}
+
+// newMessageIDs remembers IDs for window; a zero window disables dedupe
+func newMessageIDs(window time.Duration) *messageIDs {
+	return &messageIDs{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// handledMessages holds the IDs of messages already replied to
+var handledMessages = newMessageIDs(defaultDedupeWindow)
+
+// FirstSeen records id and reports whether it wasn't seen within the
+// window. Messages without an ID are always treated as new.
+func (m *messageIDs) FirstSeen(id string) bool {
+	if id == "" || m.window <= 0 {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	// Forget expired IDs once per window to keep memory bounded
+	if now.Sub(m.swept) >= m.window {
+		for seenID, at := range m.seen {
+			if now.Sub(at) >= m.window {
+				delete(m.seen, seenID)
+			}
+		}
+		m.swept = now
+	}
+
+	if at, ok := m.seen[id]; ok && now.Sub(at) < m.window {
+		return false
+	}
+	m.seen[id] = now
+	return true
+}
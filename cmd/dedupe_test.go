@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRedeliveredWebhookIsAnsweredOnce(t *testing.T) {
+	graph := newTestServer(t, `{"default_response": "Thanks for your message!"}`)
+
+	// Graph retries the same event, then the user writes again
+	for _, id := range []string{"m1", "m1", "m1", "m2"} {
+		if code := postMessage(t, id, "1234", "hello"); code != http.StatusOK {
+			t.Fatalf("webhook for %s returned %d, want 200", id, code)
+		}
+	}
+
+	want := []string{"Thanks for your message!", "Thanks for your message!"}
+	if got := graph.Texts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want one reply per message", got)
+	}
+}
+
+func TestMessageIDsForgetAfterWindow(t *testing.T) {
+	ids := newMessageIDs(20 * time.Millisecond)
+
+	if !ids.FirstSeen("m1") {
+		t.Fatal("new ID reported as seen")
+	}
+	if ids.FirstSeen("m1") {
+		t.Fatal("repeated ID reported as new")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !ids.FirstSeen("m1") {
+		t.Error("ID still remembered after the window")
+	}
+	if len(ids.seen) != 1 {
+		t.Errorf("remembering %d IDs, want expired ones swept", len(ids.seen))
+	}
+}
+
+func TestMessageIDsWithoutIDOrWindow(t *testing.T) {
+	ids := newMessageIDs(time.Minute)
+	if !ids.FirstSeen("") || !ids.FirstSeen("") {
+		t.Error("messages without an ID deduplicated")
+	}
+
+	disabled := newMessageIDs(0)
+	if !disabled.FirstSeen("m1") || !disabled.FirstSeen("m1") {
+		t.Error("IDs deduplicated with dedupe disabled")
+	}
+}
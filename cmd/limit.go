@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultMaxConcurrentHandlers is used when MAX_CONCURRENT_HANDLERS is unset
+const defaultMaxConcurrentHandlers = 64
+
+// limitConcurrency caps how many requests are handled at once. Requests over
+// the cap wait up to queueTimeout for a free slot, then get a 503.
+func limitConcurrency(next http.Handler, maxHandlers int, queueTimeout time.Duration) http.Handler {
+	slots := make(chan struct{}, maxHandlers)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			if queueTimeout <= 0 {
+				rejectBusy(w, r)
+				return
+			}
+
+			timer := time.NewTimer(queueTimeout)
+			defer timer.Stop()
+
+			select {
+			case slots <- struct{}{}:
+			case <-timer.C:
+				rejectBusy(w, r)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		defer func() { <-slots }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectBusy answers a request that exceeded the concurrency limit
+func rejectBusy(w http.ResponseWriter, r *http.Request) {
+	logger.Warn("Too many concurrent requests, rejecting", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "server busy", http.StatusServiceUnavailable)
+}
+
+// envInt reads a positive integer from the environment, falling back to def
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("Invalid environment variable, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a duration such as "2s" from the environment, falling back to def
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		logger.Warn("Invalid environment variable, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return d
+}
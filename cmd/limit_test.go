@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingHandler holds every request until release is closed and records
+// the most requests it saw at once
+type blockingHandler struct {
+	release  chan struct{}
+	entered  chan struct{}
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{release: make(chan struct{}), entered: make(chan struct{}, 100)}
+}
+
+func (h *blockingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := h.inFlight.Add(1)
+	defer h.inFlight.Add(-1)
+	for {
+		seen := h.maxSeen.Load()
+		if n <= seen || h.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	h.entered <- struct{}{}
+	<-h.release
+	w.WriteHeader(http.StatusOK)
+}
+
+// serve sends a webhook request to handler and returns the status code
+func serve(handler http.Handler) int {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", nil))
+	return rec.Code
+}
+
+func TestLimitConcurrencyRejectsFlood(t *testing.T) {
+	captureLogs(t, "error")
+
+	const maxHandlers, requests = 4, 20
+	inner := newBlockingHandler()
+	handler := limitConcurrency(inner, maxHandlers, 0)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(handler)
+		}()
+	}
+
+	// Wait for the handlers to fill up, then for every other request to be rejected
+	for i := 0; i < maxHandlers; i++ {
+		<-inner.entered
+	}
+	rejected := 0
+	for rejected < requests-maxHandlers {
+		select {
+		case code := <-codes:
+			if code != http.StatusServiceUnavailable {
+				t.Fatalf("request over the cap got %d, want 503", code)
+			}
+			rejected++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d requests rejected, want %d", rejected, requests-maxHandlers)
+		}
+	}
+
+	close(inner.release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("admitted request got %d, want 200", code)
+		}
+	}
+	if got := inner.maxSeen.Load(); got != maxHandlers {
+		t.Errorf("%d requests handled at once, want %d", got, maxHandlers)
+	}
+}
+
+func TestLimitConcurrencyQueuesUntilTimeout(t *testing.T) {
+	captureLogs(t, "error")
+
+	inner := newBlockingHandler()
+	handler := limitConcurrency(inner, 1, time.Minute)
+
+	first := make(chan int)
+	go func() { first <- serve(handler) }()
+	<-inner.entered
+
+	// The second request waits for the first to finish instead of failing
+	second := make(chan int)
+	go func() { second <- serve(handler) }()
+	close(inner.release)
+
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("first request got %d, want 200", code)
+	}
+	if code := <-second; code != http.StatusOK {
+		t.Errorf("queued request got %d, want 200", code)
+	}
+	if got := inner.maxSeen.Load(); got != 1 {
+		t.Errorf("%d requests handled at once, want 1", got)
+	}
+}
+
+func TestLimitConcurrencyTimesOutQueuedRequest(t *testing.T) {
+	captureLogs(t, "error")
+
+	inner := newBlockingHandler()
+	defer close(inner.release)
+	handler := limitConcurrency(inner, 1, 10*time.Millisecond)
+
+	go serve(handler)
+	<-inner.entered
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("got %d with Retry-After %q, want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// logger is the webhook server's structured logger, configured from
+// LOG_LEVEL and LOG_FORMAT in main
+var logger = slog.Default()
+
+// newLogger creates a logger writing to w, as JSON when format is "json"
+// and as text otherwise. An unknown level falls back to info.
+func newLogger(w io.Writer, format, level string) *slog.Logger {
+	var lvl slog.Level
+	invalidLevel := level != "" && lvl.UnmarshalText([]byte(level)) != nil
+	if invalidLevel {
+		lvl = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var l *slog.Logger
+	if strings.EqualFold(format, "json") {
+		l = slog.New(slog.NewJSONHandler(w, opts))
+	} else {
+		l = slog.New(slog.NewTextHandler(w, opts))
+	}
+
+	if invalidLevel {
+		l.Warn("Invalid LOG_LEVEL, using info", "value", level)
+	}
+	return l
+}
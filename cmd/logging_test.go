@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends the server's logs to the returned buffer as JSON for
+// the rest of the test
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := logger
+	logger = newLogger(&buf, "json", level)
+	t.Cleanup(func() { logger = saved })
+	return &buf
+}
+
+// logRecords decodes the JSON lines written to buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestWebhookLogsAreStructuredJSON(t *testing.T) {
+	buf := captureLogs(t, "info")
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{not json"))
+	req.RemoteAddr = "203.0.113.7:4242"
+	handleWebhook(httptest.NewRecorder(), req)
+
+	records := logRecords(t, buf)
+	if len(records) != 2 {
+		t.Fatalf("got %d log records, want 2: %s", len(records), buf)
+	}
+
+	incoming := records[0]
+	if incoming["level"] != "INFO" || incoming["msg"] != "Incoming webhook" {
+		t.Errorf("first record = %v, want the incoming webhook at INFO", incoming)
+	}
+	if incoming["method"] != "POST" || incoming["path"] != "/webhook" || incoming["remote_addr"] != "203.0.113.7:4242" {
+		t.Errorf("first record fields = %v", incoming)
+	}
+
+	decodeErr := records[1]
+	if decodeErr["level"] != "ERROR" || decodeErr["msg"] != "Error decoding webhook payload" {
+		t.Errorf("second record = %v, want the decode error at ERROR", decodeErr)
+	}
+	if _, ok := decodeErr["error"].(string); !ok {
+		t.Errorf("decode error record has no error field: %v", decodeErr)
+	}
+}
+
+func TestLoggerHonoursLevel(t *testing.T) {
+	buf := captureLogs(t, "warn")
+
+	req := httptest.NewRequest(http.MethodGet, "/webhook?hub.mode=subscribe&hub.verify_token="+verifyToken, nil)
+	handleWebhook(httptest.NewRecorder(), req)
+
+	if buf.Len() != 0 {
+		t.Errorf("info records logged at warn level: %s", buf)
+	}
+}
+
+func TestLoggerFallsBackToInfoForUnknownLevel(t *testing.T) {
+	buf := captureLogs(t, "chatty")
+
+	records := logRecords(t, buf)
+	if len(records) != 1 || records[0]["level"] != "WARN" || records[0]["value"] != "chatty" {
+		t.Fatalf("records = %v, want one warning about the level", records)
+	}
+
+	logger.Debug("hidden")
+	logger.Info("shown")
+	records = logRecords(t, buf)
+	if len(records) != 2 || records[1]["msg"] != "shown" {
+		t.Errorf("records = %v, want only the info record added", records)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"os"
 )
 
 const (
@@ -13,13 +13,27 @@ const (
 )
 
 func main() {
-	http.HandleFunc("/webhook", handleWebhook)
-	log.Println("🌐 Webhook server is running on port 8080...")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	logger = newLogger(os.Stdout, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile == "" {
+		configFile = "config.json"
+	}
+	responses = loadResponses(configFile)
+	handledMessages = newMessageIDs(envDuration("DEDUPE_WINDOW", defaultDedupeWindow))
+
+	maxHandlers := envInt("MAX_CONCURRENT_HANDLERS", defaultMaxConcurrentHandlers)
+	queueTimeout := envDuration("HANDLER_QUEUE_TIMEOUT", 0)
+
+	http.Handle("/webhook", limitConcurrency(http.HandlerFunc(handleWebhook), maxHandlers, queueTimeout))
+	logger.Info("Webhook server is running", "addr", ":8080")
+	err := http.ListenAndServe(":8080", nil)
+	logger.Error("Webhook server stopped", "error", err)
+	os.Exit(1)
 }
 
 func handleWebhook(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Incoming Webhook: method: %v | url: %v | remoteAddr: %v", r.Method, r.URL.Path, r.RemoteAddr)
+	logger.Info("Incoming webhook", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
 	if r.Method == http.MethodGet {
 		verifyWebhook(w, r)
 		return
@@ -40,7 +54,7 @@ func verifyWebhook(w http.ResponseWriter, r *http.Request) {
 
 	if mode == "subscribe" && token == verifyToken {
 		fmt.Fprintf(w, "%s", challenge)
-		log.Println("✅ Webhook verified successfully!")
+		logger.Info("Webhook verified")
 		return
 	}
 
@@ -52,12 +66,12 @@ func handleIncomingMessage(w http.ResponseWriter, r *http.Request) {
 
 	var payload map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		log.Printf("❌ Error decoding webhook payload: %v", err)
+		logger.Error("Error decoding webhook payload", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("📨 Incoming Message Webhook: %+v\n", payload)
+	logger.Debug("Incoming message webhook", "payload", payload)
 
 	// Extract sender ID and message text (simplified)
 	entry := payload["entry"].([]interface{})[0].(map[string]interface{})
@@ -69,12 +83,22 @@ func handleIncomingMessage(w http.ResponseWriter, r *http.Request) {
 		msg := messages[0].(map[string]interface{})
 		senderID := msg["from"].(string)
 
-		log.Printf("🔔 New message from: %s", senderID)
+		// Graph redelivers events it didn't get a timely 200 for
+		messageID, _ := msg["id"].(string)
+		if !handledMessages.FirstSeen(messageID) {
+			logger.Info("Skipping already handled message", "message_id", messageID, "user_id", senderID)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		logger.Info("New message", "message_id", messageID, "user_id", senderID)
 
 		// Send a reply
-		err := sendReply(senderID, "👋 Hello! Thanks for messaging us.")
-		if err != nil {
-			log.Printf("❌ Failed to send reply: %v", err)
+		response := responses.Respond(messageText(msg))
+		if err := sendReply(senderID, response); err != nil {
+			logger.Error("Failed to send reply", "message_id", messageID, "user_id", senderID, "error", err)
+		} else {
+			logger.Info("Sent reply", "message_id", messageID, "user_id", senderID, "response", response)
 		}
 	}
 
@@ -85,19 +109,19 @@ const (
 	pageAccessToken = "YOUR_PAGE_ACCESS_TOKEN"
 )
 
+// graphAPIURL is the Graph API send endpoint, replaced in tests
+var graphAPIURL = "https://graph.facebook.com/v18.0/me/messages"
+
+// sendReply sends a plain text message
 func sendReply(recipientID, messageText string) error {
-	url := fmt.Sprintf("https://graph.facebook.com/v18.0/me/messages?access_token=%s", pageAccessToken)
+	return sendMessage(recipientID, OutgoingMessage{Text: messageText})
+}
 
-	messageData := map[string]interface{}{
-		"recipient": map[string]interface{}{
-			"id": recipientID,
-		},
-		"message": map[string]interface{}{
-			"text": messageText,
-		},
-	}
+// sendMessage sends a text, quick-reply or button message
+func sendMessage(recipientID string, msg OutgoingMessage) error {
+	url := fmt.Sprintf("%s?access_token=%s", graphAPIURL, pageAccessToken)
 
-	body, err := json.Marshal(messageData)
+	body, err := marshalMessage(recipientID, msg)
 	if err != nil {
 		return err
 	}
@@ -114,3 +138,20 @@ func sendReply(recipientID, messageText string) error {
 
 	return nil
 }
+
+// marshalMessage builds the Graph API send request body
+func marshalMessage(recipientID string, msg OutgoingMessage) ([]byte, error) {
+	message, err := msg.graphMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	messageData := map[string]interface{}{
+		"recipient": map[string]interface{}{
+			"id": recipientID,
+		},
+		"message": message,
+	}
+
+	return json.Marshal(messageData)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// graphMessages is a fake Graph API recording the texts sent to it
+type graphMessages struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (g *graphMessages) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message struct {
+			Text string `json:"text"`
+		} `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.texts = append(g.texts, req.Message.Text)
+}
+
+func (g *graphMessages) Texts() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.texts...)
+}
+
+// newTestServer points the webhook at a fake Graph API, answering with
+// the response config in configJSON, and restores the globals afterwards
+func newTestServer(t *testing.T, configJSON string) *graphMessages {
+	t.Helper()
+	captureLogs(t, "error")
+
+	graph := &graphMessages{}
+	server := httptest.NewServer(graph)
+	t.Cleanup(server.Close)
+
+	savedURL, savedResponses, savedHandled := graphAPIURL, responses, handledMessages
+	t.Cleanup(func() { graphAPIURL, responses, handledMessages = savedURL, savedResponses, savedHandled })
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(configJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	graphAPIURL = server.URL
+	responses = loadResponses(path)
+	handledMessages = newMessageIDs(defaultDedupeWindow)
+	return graph
+}
+
+// postMessage delivers a webhook for a message from senderID and returns
+// the status code
+func postMessage(t *testing.T, id, senderID, text string) int {
+	t.Helper()
+	payload, err := json.Marshal(map[string]interface{}{
+		"entry": []interface{}{map[string]interface{}{
+			"changes": []interface{}{map[string]interface{}{
+				"value": map[string]interface{}{
+					"messages": []interface{}{map[string]interface{}{
+						"id":   id,
+						"from": senderID,
+						"text": map[string]string{"body": text},
+					}},
+				},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handleWebhook(rec, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(string(payload))))
+	return rec.Code
+}
+
+func TestWebhookRepliesFromSharedRules(t *testing.T) {
+	graph := newTestServer(t, `{
+		"default_response": "Thanks for your message!",
+		"response_rules": {
+			"price": "Our prices start at $10.",
+			"hola": {"text": "Hello!", "translations": {"es": "¡Hola! ¿En qué podemos ayudarte?"}}
+		}
+	}`)
+
+	for i, text := range []string{"What is the price?", "Hola, ¿tienen envío gratis?", "xqzv"} {
+		if code := postMessage(t, fmt.Sprintf("m%d", i), "1234", text); code != http.StatusOK {
+			t.Fatalf("webhook returned %d", code)
+		}
+	}
+
+	want := []string{"Our prices start at $10.", "¡Hola! ¿En qué podemos ayudarte?", "Thanks for your message!"}
+	if got := graph.Texts(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestWebhookFallsBackToGreetingWithoutConfig(t *testing.T) {
+	graph := newTestServer(t, `{not json`)
+
+	postMessage(t, "m1", "1234", "What is the price?")
+	if got := graph.Texts(); len(got) != 1 || got[0] != defaultGreeting {
+		t.Errorf("sent %q, want the default greeting", got)
+	}
+}
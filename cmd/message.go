@@ -0,0 +1,105 @@
+package main
+
+import "errors"
+
+// Graph API button types
+const (
+	ButtonPostback = "postback"
+	ButtonWebURL   = "web_url"
+)
+
+// OutgoingMessage is a message sent through the Graph API: plain text,
+// optionally with quick replies or with buttons
+type OutgoingMessage struct {
+	Text         string
+	QuickReplies []QuickReply
+	Buttons      []Button
+}
+
+// QuickReply is a suggested reply shown under a message. Tapping it sends
+// Title back as a message carrying Payload.
+type QuickReply struct {
+	Title   string
+	Payload string
+}
+
+// Button is a button in a button template. Postback buttons send Payload
+// back to the webhook, web_url buttons open URL.
+type Button struct {
+	Type    string
+	Title   string
+	Payload string
+	URL     string
+}
+
+// graphMessage is the "message" object of a Graph API send request
+type graphMessage struct {
+	Text         string            `json:"text,omitempty"`
+	QuickReplies []graphQuickReply `json:"quick_replies,omitempty"`
+	Attachment   *graphAttachment  `json:"attachment,omitempty"`
+}
+
+type graphQuickReply struct {
+	ContentType string `json:"content_type"`
+	Title       string `json:"title"`
+	Payload     string `json:"payload"`
+}
+
+type graphAttachment struct {
+	Type    string          `json:"type"`
+	Payload graphButtonPage `json:"payload"`
+}
+
+type graphButtonPage struct {
+	TemplateType string        `json:"template_type"`
+	Text         string        `json:"text"`
+	Buttons      []graphButton `json:"buttons"`
+}
+
+type graphButton struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Payload string `json:"payload,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// graphMessage converts the message into the Graph API JSON shape. Messages
+// with buttons are sent as a button template carrying the text.
+func (m OutgoingMessage) graphMessage() (graphMessage, error) {
+	if m.Text == "" {
+		return graphMessage{}, errors.New("message text is required")
+	}
+
+	var msg graphMessage
+	if len(m.Buttons) == 0 {
+		msg.Text = m.Text
+	} else {
+		page := graphButtonPage{TemplateType: "button", Text: m.Text}
+		for _, b := range m.Buttons {
+			kind := b.Type
+			if kind == "" {
+				kind = ButtonPostback
+				if b.URL != "" {
+					kind = ButtonWebURL
+				}
+			}
+			page.Buttons = append(page.Buttons, graphButton{
+				Type:    kind,
+				Title:   b.Title,
+				Payload: b.Payload,
+				URL:     b.URL,
+			})
+		}
+		msg.Attachment = &graphAttachment{Type: "template", Payload: page}
+	}
+
+	for _, qr := range m.QuickReplies {
+		msg.QuickReplies = append(msg.QuickReplies, graphQuickReply{
+			ContentType: "text",
+			Title:       qr.Title,
+			Payload:     qr.Payload,
+		})
+	}
+
+	return msg, nil
+}
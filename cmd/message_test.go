@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// assertJSON fails unless got and want encode the same JSON value
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON: %v", err)
+	}
+	gotJSON, _ := json.Marshal(gotValue)
+	wantJSON, _ := json.Marshal(wantValue)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("got  %s\nwant %s", gotJSON, wantJSON)
+	}
+}
+
+func TestMarshalTextMessage(t *testing.T) {
+	body, err := marshalMessage("1234", OutgoingMessage{Text: "Hello!"})
+	if err != nil {
+		t.Fatalf("marshalMessage: %v", err)
+	}
+	assertJSON(t, body, `{"recipient": {"id": "1234"}, "message": {"text": "Hello!"}}`)
+}
+
+func TestMarshalQuickReplyMessage(t *testing.T) {
+	body, err := marshalMessage("1234", OutgoingMessage{
+		Text: "What are you looking for?",
+		QuickReplies: []QuickReply{
+			{Title: "Prices", Payload: "PRICES"},
+			{Title: "Opening hours", Payload: "HOURS"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("marshalMessage: %v", err)
+	}
+	assertJSON(t, body, `{
+		"recipient": {"id": "1234"},
+		"message": {
+			"text": "What are you looking for?",
+			"quick_replies": [
+				{"content_type": "text", "title": "Prices", "payload": "PRICES"},
+				{"content_type": "text", "title": "Opening hours", "payload": "HOURS"}
+			]
+		}
+	}`)
+}
+
+func TestMarshalButtonMessage(t *testing.T) {
+	body, err := marshalMessage("1234", OutgoingMessage{
+		Text: "How can we help?",
+		Buttons: []Button{
+			{Title: "Talk to us", Payload: "HUMAN"},
+			{Title: "Visit the shop", URL: "https://example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("marshalMessage: %v", err)
+	}
+	assertJSON(t, body, `{
+		"recipient": {"id": "1234"},
+		"message": {
+			"attachment": {
+				"type": "template",
+				"payload": {
+					"template_type": "button",
+					"text": "How can we help?",
+					"buttons": [
+						{"type": "postback", "title": "Talk to us", "payload": "HUMAN"},
+						{"type": "web_url", "title": "Visit the shop", "url": "https://example.com"}
+					]
+				}
+			}
+		}
+	}`)
+}
+
+func TestMarshalRejectsEmptyText(t *testing.T) {
+	if _, err := marshalMessage("1234", OutgoingMessage{QuickReplies: []QuickReply{{Title: "Yes", Payload: "YES"}}}); err == nil {
+		t.Error("no error for a message without text")
+	}
+}
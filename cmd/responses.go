@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+// defaultGreeting is sent when no response config could be loaded
+const defaultGreeting = "👋 Hello! Thanks for messaging us."
+
+// responses picks the reply to incoming messages
+var responses = responder.NewResponseEngine(responder.Config{DefaultResponse: defaultGreeting})
+
+// loadResponses builds the response engine from the response rules in the
+// config file shared with the unofficial bot
+func loadResponses(path string) *responder.ResponseEngine {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Warn("Could not read response config, replying with the default greeting", "path", path, "error", err)
+		return responses
+	}
+
+	var cfg responder.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		logger.Warn("Could not parse response config, replying with the default greeting", "path", path, "error", err)
+		return responses
+	}
+	if cfg.DefaultResponse == "" {
+		cfg.DefaultResponse = defaultGreeting
+	}
+
+	rules := len(cfg.ResponseRules)
+	for _, localized := range cfg.ResponsesByLang {
+		rules += len(localized)
+	}
+	logger.Info("Loaded response rules", "rules", rules, "path", path)
+	return responder.NewResponseEngine(cfg).WithLogger(logger)
+}
+
+// messageText extracts the text of a webhook message, which is either a
+// plain string or a {"body": "..."} object
+func messageText(msg map[string]interface{}) string {
+	switch text := msg["text"].(type) {
+	case string:
+		return text
+	case map[string]interface{}:
+		body, _ := text["body"].(string)
+		return body
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+// Package responder holds the reply logic shared by the official webhook
+// server and the unofficial bot: keyword rules, translations and templates.
+package responder
+
+import (
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Config holds the response settings. Its JSON keys match config.json, so
+// it can be decoded from the same file as the rest of the configuration.
+type Config struct {
+	ResponseRules   map[string]Response `json:"response_rules"`
+	DefaultResponse string              `json:"default_response"`
+	// DefaultLanguage picks the rule translation when the message's
+	// language is unknown or not translated
+	DefaultLanguage string `json:"default_language"`
+
+	// ResponsesByLang holds keyword rules per language, e.g.
+	// {"es": {"precio": "..."}}. They are checked before ResponseRules,
+	// using the DefaultLanguage set when the sender's language has none.
+	ResponsesByLang map[string]map[string]string `json:"responses_by_lang"`
+	// DefaultResponses replaces DefaultResponse for the languages it lists
+	DefaultResponses map[string]string `json:"default_responses"`
+}
+
+// FallbackLanguage returns the configured default language, or English
+func (c Config) FallbackLanguage() string {
+	if c.DefaultLanguage == "" {
+		return defaultLanguage
+	}
+	return c.DefaultLanguage
+}
+
+// ResponseData holds the variables available to response templates,
+// e.g. "Hi {{.Username}}, thanks for reaching out!"
+type ResponseData struct {
+	Username string
+	FullName string
+	Now      time.Time
+}
+
+// ResponseEngine picks and renders the reply to an incoming message
+type ResponseEngine struct {
+	config Config
+	logger *slog.Logger
+}
+
+// NewResponseEngine creates an engine for the given response settings
+func NewResponseEngine(cfg Config) *ResponseEngine {
+	return &ResponseEngine{
+		config: cfg,
+		logger: slog.Default(),
+	}
+}
+
+// WithLogger sets where template warnings are logged
+func (e *ResponseEngine) WithLogger(logger *slog.Logger) *ResponseEngine {
+	e.logger = logger
+	return e
+}
+
+// Respond returns the rendered reply to messageText
+func (e *ResponseEngine) Respond(messageText string) string {
+	response, _ := e.Match(messageText, false)
+	return e.Render(response, ResponseData{Now: time.Now()})
+}
+
+// Match selects the response for messageText in the sender's language,
+// reporting whether a response rule matched. Rules for returning users only
+// match when returning is set.
+func (e *ResponseEngine) Match(messageText string, returning bool) (string, bool) {
+	messageText = strings.ToLower(messageText)
+
+	fallbackLang := e.config.FallbackLanguage()
+	lang, ok := detectLanguage(messageText)
+	if !ok {
+		lang = fallbackLang
+	}
+
+	// Rules written for the sender's language come first
+	rules, ok := e.config.ResponsesByLang[lang]
+	if !ok {
+		rules = e.config.ResponsesByLang[fallbackLang]
+	}
+	for pattern, response := range rules {
+		if strings.Contains(messageText, pattern) {
+			return response, true
+		}
+	}
+
+	// Check for keyword matches, replying in the sender's language
+	for pattern, response := range e.config.ResponseRules {
+		if response.RequireReturningUser && !returning {
+			continue
+		}
+		if strings.Contains(messageText, pattern) {
+			return response.In(lang, fallbackLang), true
+		}
+	}
+
+	// Return default response if no match
+	return e.defaultResponse(lang, fallbackLang), false
+}
+
+// Default returns the default response for messages without text, whose
+// language can't be detected
+func (e *ResponseEngine) Default() string {
+	lang := e.config.FallbackLanguage()
+	return e.defaultResponse(lang, lang)
+}
+
+// defaultResponse returns the default response for lang, falling back to
+// fallbackLang and then to DefaultResponse
+func (e *ResponseEngine) defaultResponse(lang, fallbackLang string) string {
+	if text := e.config.DefaultResponses[lang]; text != "" {
+		return text
+	}
+	if text := e.config.DefaultResponses[fallbackLang]; text != "" {
+		return text
+	}
+	return e.config.DefaultResponse
+}
+
+// Render executes response as a text/template. Responses that fail to
+// parse or reference unknown fields are returned verbatim instead.
+func (e *ResponseEngine) Render(response string, data ResponseData) string {
+	if !strings.Contains(response, "{{") {
+		return response
+	}
+
+	tmpl, err := template.New("response").Option("missingkey=error").Parse(response)
+	if err != nil {
+		e.logger.Warn("Invalid response template, sending as-is", "response", response, "error", err)
+		return response
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		e.logger.Warn("Failed to render response template, sending as-is", "response", response, "error", err)
+		return response
+	}
+
+	return rendered.String()
+}
@@ -0,0 +1,134 @@
+package responder
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// localizedConfig has English and Spanish response sets
+var localizedConfig = Config{
+	DefaultResponse: "Thanks for your message!",
+	DefaultLanguage: "en",
+	ResponsesByLang: map[string]map[string]string{
+		"en": {"price": "Our prices start at $10."},
+		"es": {"precio": "Nuestros precios empiezan en $10."},
+	},
+	DefaultResponses: map[string]string{
+		"es": "¡Gracias por tu mensaje!",
+	},
+}
+
+func TestMatchPicksLanguageBucket(t *testing.T) {
+	engine := NewResponseEngine(localizedConfig)
+
+	tests := []struct {
+		name        string
+		message     string
+		want        string
+		wantMatched bool
+	}{
+		{"english rule", "Hi, what is the price?", "Our prices start at $10.", true},
+		{"spanish rule", "Hola, ¿cuál es el precio?", "Nuestros precios empiezan en $10.", true},
+		{"spanish default", "Hola, gracias por todo", "¡Gracias por tu mensaje!", false},
+		{"gibberish falls back", "xqzv blorp", "Thanks for your message!", false},
+		{"unconfigured language falls back", "Bonjour, le prix?", "Thanks for your message!", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := engine.Match(tt.message, false)
+			if got != tt.want || matched != tt.wantMatched {
+				t.Errorf("Match(%q) = %q, %v; want %q, %v", tt.message, got, matched, tt.want, tt.wantMatched)
+			}
+		})
+	}
+}
+
+func TestMatchWithoutLocalizedSets(t *testing.T) {
+	engine := NewResponseEngine(Config{
+		ResponseRules:   map[string]Response{"price": {Text: "From $10."}},
+		DefaultResponse: "Thanks!",
+	})
+
+	if got, _ := engine.Match("Hola, ¿cuál es el precio?", false); got != "Thanks!" {
+		t.Errorf("Spanish message got %q, want the single-language default", got)
+	}
+	if got, _ := engine.Match("What is the price?", false); got != "From $10." {
+		t.Errorf("English message got %q, want the rule", got)
+	}
+}
+
+func TestDefaultUsesDefaultLanguage(t *testing.T) {
+	config := localizedConfig
+	config.DefaultLanguage = "es"
+	if got := NewResponseEngine(config).Default(); got != "¡Gracias por tu mensaje!" {
+		t.Errorf("Default() = %q, want the Spanish default", got)
+	}
+	if got := NewResponseEngine(localizedConfig).Default(); got != "Thanks for your message!" {
+		t.Errorf("Default() = %q, want DefaultResponse", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := ResponseData{
+		Username: "alice",
+		FullName: "Alice Smith",
+		Now:      time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantWarn bool
+	}{
+		{"substitution", "Hi {{.Username}} ({{.FullName}}), it's {{.Now.Format \"15:04\"}}.", "Hi alice (Alice Smith), it's 09:30.", false},
+		{"unknown field", "Hi {{.Nickname}}!", "Hi {{.Nickname}}!", true},
+		{"parse error", "Hi {{.Username", "Hi {{.Username", true},
+		{"plain string", "Thanks for your message!", "Thanks for your message!", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			engine := NewResponseEngine(Config{}).WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+			if got := engine.Render(tt.response, data); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.response, got, tt.want)
+			}
+			if warned := strings.Contains(logs.String(), "level=WARN"); warned != tt.wantWarn {
+				t.Errorf("warning logged = %v, want %v: %s", warned, tt.wantWarn, logs.String())
+			}
+		})
+	}
+}
+
+func TestRespondRendersMatchedRule(t *testing.T) {
+	engine := NewResponseEngine(Config{
+		ResponseRules:   map[string]Response{"hours": {Text: "We're open until 6pm, today is {{.Now.Weekday}}."}},
+		DefaultResponse: "Thanks!",
+	})
+
+	got := engine.Respond("What are your hours?")
+	want := "We're open until 6pm, today is " + time.Now().Weekday().String() + "."
+	if got != want {
+		t.Errorf("Respond = %q, want %q", got, want)
+	}
+}
+
+func TestMatchReturningUserRules(t *testing.T) {
+	engine := NewResponseEngine(Config{
+		ResponseRules: map[string]Response{
+			"order": {Text: "Welcome back! Want to reorder?", RequireReturningUser: true},
+		},
+		DefaultResponse: "Thanks!",
+	})
+
+	if got, matched := engine.Match("I'd like to order", false); got != "Thanks!" || matched {
+		t.Errorf("new user got %q, %v; want the default", got, matched)
+	}
+	if got, matched := engine.Match("I'd like to ORDER", true); got != "Welcome back! Want to reorder?" || !matched {
+		t.Errorf("returning user got %q, %v; want the returning rule", got, matched)
+	}
+}
@@ -0,0 +1,62 @@
+package responder
+
+import (
+	"strings"
+	"unicode"
+)
+
+// defaultLanguage is used when no default language is configured
+const defaultLanguage = "en"
+
+// languageWords lists common words that identify each supported language
+var languageWords = map[string][]string{
+	"en": {"the", "and", "you", "is", "are", "what", "how", "hello", "hi", "thanks", "thank", "please", "price", "do", "have", "can", "my", "your", "for", "with", "this", "when"},
+	"es": {"el", "los", "las", "que", "y", "es", "por", "hola", "gracias", "precio", "cuánto", "cuanto", "usted", "tienen", "quiero", "cómo", "está", "buenos", "días"},
+	"fr": {"le", "les", "et", "est", "bonjour", "merci", "prix", "vous", "je", "pour", "avec", "combien", "qui", "une", "des", "ça", "salut"},
+	"de": {"der", "die", "das", "und", "ist", "hallo", "danke", "preis", "sie", "ich", "für", "mit", "wie", "nicht", "ein", "eine", "guten"},
+	"pt": {"o", "os", "é", "olá", "ola", "obrigado", "obrigada", "preço", "você", "voce", "quanto", "não", "um", "bom", "dia"},
+	"it": {"il", "gli", "è", "ciao", "grazie", "prezzo", "che", "sono", "non", "buongiorno", "quanto", "costa"},
+	"ru": {"и", "в", "не", "что", "здравствуйте", "привет", "спасибо", "цена", "сколько", "как", "вы", "я", "это"},
+	"uz": {"salom", "assalomu", "alaykum", "rahmat", "narxi", "qancha", "bormi", "men", "siz", "kerak", "qanday", "yaxshi"},
+}
+
+// languageIndex maps each word to the languages it identifies
+var languageIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range languageWords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// detectLanguage guesses the language of text by counting common words.
+// It reports false when no language clearly scores highest.
+func detectLanguage(text string) (string, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	scores := make(map[string]int)
+	for _, word := range words {
+		for _, lang := range languageIndex[word] {
+			scores[lang]++
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, runnerUp = lang, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+
+	if bestScore == 0 || bestScore == runnerUp {
+		return "", false
+	}
+	return best, true
+}
@@ -0,0 +1,65 @@
+package responder
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Response is the reply for a response rule, optionally translated into
+// several languages. In config.json it is either a plain string or an
+// object such as {"text": "Hi!", "translations": {"es": "¡Hola!"}}.
+type Response struct {
+	Text         string            `json:"text"`
+	Translations map[string]string `json:"translations"`
+
+	// RequireReturningUser only matches users who have messaged before.
+	// Users already auto-replied to aren't answered again, so this targets
+	// users whose earlier messages went unanswered.
+	RequireReturningUser bool `json:"require_returning_user"`
+}
+
+// UnmarshalJSON accepts both the plain string and the object form
+func (r *Response) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*r = Response{Text: text}
+		return nil
+	}
+
+	// Decode through an alias type to avoid recursing into this method
+	type response Response
+	var decoded response
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("response must be a string or an object: %w", err)
+	}
+	*r = Response(decoded)
+	return nil
+}
+
+// In returns the response in lang, falling back to fallbackLang and then
+// to the untranslated text
+func (r Response) In(lang, fallbackLang string) string {
+	if text, ok := r.Translations[lang]; ok && text != "" {
+		return text
+	}
+	if text, ok := r.Translations[fallbackLang]; ok && text != "" {
+		return text
+	}
+	if r.Text != "" {
+		return r.Text
+	}
+
+	// Only translations into other languages exist, pick one deterministically
+	langs := make([]string, 0, len(r.Translations))
+	for l := range r.Translations {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	for _, l := range langs {
+		if r.Translations[l] != "" {
+			return r.Translations[l]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package responder
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRuleTranslationMatchesMessageLanguage(t *testing.T) {
+	var cfg Config
+	err := json.Unmarshal([]byte(`{
+		"default_response": "Thanks for your message!",
+		"default_language": "en",
+		"response_rules": {
+			"hola": {"text": "Hello! How can we help?", "translations": {"es": "¡Hola! ¿En qué podemos ayudarte?"}},
+			"price": "Our prices start at $10."
+		}
+	}`), &cfg)
+	if err != nil {
+		t.Fatalf("decoding config: %v", err)
+	}
+	engine := NewResponseEngine(cfg)
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"spanish message", "Hola, ¿tienen envío gratis?", "¡Hola! ¿En qué podemos ayudarte?"},
+		{"english message", "hola, do you ship to Canada?", "Hello! How can we help?"},
+		{"plain string rule", "What is the price?", "Our prices start at $10."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := engine.Respond(tt.message); got != tt.want {
+				t.Errorf("Respond(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseIn(t *testing.T) {
+	r := Response{Text: "Hi!", Translations: map[string]string{"es": "¡Hola!", "fr": "Salut !"}}
+	onlyTranslated := Response{Translations: map[string]string{"fr": "Salut !", "de": "Hallo!"}}
+
+	tests := []struct {
+		name     string
+		response Response
+		lang     string
+		fallback string
+		want     string
+	}{
+		{"detected language", r, "es", "en", "¡Hola!"},
+		{"fallback language", r, "de", "fr", "Salut !"},
+		{"untranslated text", r, "de", "en", "Hi!"},
+		{"first translation by language", onlyTranslated, "es", "en", "Hallo!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.response.In(tt.lang, tt.fallback); got != tt.want {
+				t.Errorf("In(%q, %q) = %q, want %q", tt.lang, tt.fallback, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseRejectsInvalidJSON(t *testing.T) {
+	var r Response
+	if err := json.Unmarshal([]byte(`42`), &r); err == nil {
+		t.Error("no error for a number")
+	}
+}
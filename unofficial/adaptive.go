@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Davincible/goinsta"
+)
+
+// recoverAfter is the number of successful requests needed to raise the
+// concurrency limit by one after it was lowered
+const recoverAfter = 5
+
+// adaptiveLimiter bounds how many conversations are processed at once. The
+// limit halves whenever Instagram signals rate limiting and grows back one
+// step at a time while requests succeed.
+type adaptiveLimiter struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	limit     int
+	max       int
+	inFlight  int
+	successes int
+}
+
+// newAdaptiveLimiter creates a limiter starting at, and never exceeding, max
+func newAdaptiveLimiter(max int) *adaptiveLimiter {
+	if max < 1 {
+		max = 1
+	}
+	l := &adaptiveLimiter{limit: max, max: max}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Acquire blocks until a slot within the current limit is free
+func (l *adaptiveLimiter) Acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+}
+
+// Release frees a slot taken by Acquire
+func (l *adaptiveLimiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.cond.Broadcast()
+}
+
+// Observe adjusts the limit from the outcome of an Instagram request
+func (l *adaptiveLimiter) Observe(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case isRateLimited(err):
+		l.successes = 0
+		if l.limit > 1 {
+			l.limit /= 2
+		}
+	case err == nil:
+		l.successes++
+		if l.successes >= recoverAfter && l.limit < l.max {
+			l.limit++
+			l.successes = 0
+			l.cond.Broadcast()
+		}
+	}
+}
+
+// SetMax changes the largest limit, lowering the current limit if needed
+func (l *adaptiveLimiter) SetMax(max int) {
+	if max < 1 {
+		max = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = max
+	if l.limit > max {
+		l.limit = max
+	}
+	l.cond.Broadcast()
+}
+
+// Limit returns the current concurrency limit
+func (l *adaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// isRateLimited reports whether err is Instagram's 429 response
+func isRateLimited(err error) bool {
+	return errors.Is(err, goinsta.ErrTooManyRequests)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+func TestLimiterBacksOffOnRateLimitAndRecovers(t *testing.T) {
+	l := newAdaptiveLimiter(8)
+	tooMany := fmt.Errorf("sending: %w", goinsta.ErrTooManyRequests)
+
+	for _, want := range []int{4, 2, 1, 1} {
+		l.Observe(tooMany)
+		if got := l.Limit(); got != want {
+			t.Fatalf("limit after 429 = %d, want %d", got, want)
+		}
+	}
+
+	// Other errors neither lower nor raise the limit
+	l.Observe(errors.New("connection reset"))
+	if got := l.Limit(); got != 1 {
+		t.Fatalf("limit after another error = %d, want 1", got)
+	}
+
+	// Each run of successes raises the limit by one, up to the maximum
+	for want := 2; want <= 8; want++ {
+		for i := 0; i < recoverAfter; i++ {
+			l.Observe(nil)
+		}
+		if got := l.Limit(); got != want {
+			t.Fatalf("limit after %d successes = %d, want %d", recoverAfter, got, want)
+		}
+	}
+	for i := 0; i < recoverAfter; i++ {
+		l.Observe(nil)
+	}
+	if got := l.Limit(); got != 8 {
+		t.Errorf("limit = %d, want it capped at 8", got)
+	}
+}
+
+func TestLimiterHoldsWorkersAboveLoweredLimit(t *testing.T) {
+	l := newAdaptiveLimiter(2)
+	l.Observe(goinsta.ErrTooManyRequests)
+
+	l.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		l.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second worker ran although the limit is 1")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second worker still waiting after a release")
+	}
+	l.Release()
+}
+
+func TestRateLimitedSendsLowerBotConcurrency(t *testing.T) {
+	config := newTestConfig(t)
+	config.Concurrency = 4
+	bot, sender := newTestBot(t, config)
+
+	rateLimited := true
+	sender.fail = func(string) error {
+		if rateLimited {
+			return goinsta.ErrTooManyRequests
+		}
+		return nil
+	}
+
+	conversation := func(i int) *goinsta.Conversation {
+		id := fmt.Sprintf("t%d", i)
+		userID := int64(100 + i)
+		return newTestConversation(id, userID, textItem("i"+id, userID, "hello", time.Now()))
+	}
+
+	bot.processConversations([]*goinsta.Conversation{conversation(1), conversation(2)})
+	if got := bot.limiter.Limit(); got != 1 {
+		t.Fatalf("limit after two 429s = %d, want 1", got)
+	}
+
+	// Instagram recovers
+	rateLimited = false
+	var conversations []*goinsta.Conversation
+	for i := 10; i < 10+3*recoverAfter; i++ {
+		conversations = append(conversations, conversation(i))
+	}
+	bot.processConversations(conversations)
+	if got := bot.limiter.Limit(); got != 4 {
+		t.Errorf("limit after recovering = %d, want 4", got)
+	}
+}
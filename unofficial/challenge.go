@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// Verification code sources
+const (
+	CodeSourceStdin = "stdin"
+	CodeSourceFile  = "file"
+)
+
+const (
+	// defaultCodeTimeout is how long to wait for a code file to appear
+	defaultCodeTimeout = 5 * time.Minute
+	// codePollInterval is how often the code file is checked
+	codePollInterval = 2 * time.Second
+)
+
+// CodeProvider supplies the verification code for a login step. kind
+// describes the step, either "two-factor" or "challenge".
+type CodeProvider func(kind string) (string, error)
+
+// newCodeProvider returns the code provider selected by the configuration
+func newCodeProvider(config *Configuration) CodeProvider {
+	if config.TwoFactorCodeSource == CodeSourceFile {
+		timeout := defaultCodeTimeout
+		if config.TwoFactorCodeTimeout > 0 {
+			timeout = time.Duration(config.TwoFactorCodeTimeout) * time.Second
+		}
+		return fileCodeProvider(config.TwoFactorCodeFile, timeout)
+	}
+	return stdinCodeProvider(config.Username)
+}
+
+// stdinMu keeps prompts for several accounts from interleaving
+var stdinMu sync.Mutex
+
+// stdinCodeProvider prompts for the account's code on the terminal
+func stdinCodeProvider(account string) CodeProvider {
+	return func(kind string) (string, error) {
+		stdinMu.Lock()
+		defer stdinMu.Unlock()
+
+		fmt.Printf("Enter the %s code sent to %s: ", kind, account)
+		code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("error reading %s code: %w", kind, err)
+		}
+		return strings.TrimSpace(code), nil
+	}
+}
+
+// fileCodeProvider waits for a code to be written to path, which lets
+// headless deployments supply codes without a terminal. The file is
+// removed once read so a stale code is never reused.
+func fileCodeProvider(path string, timeout time.Duration) CodeProvider {
+	return func(kind string) (string, error) {
+		deadline := time.Now().Add(timeout)
+		for {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				if code := strings.TrimSpace(string(data)); code != "" {
+					if err := os.Remove(path); err != nil {
+						return "", fmt.Errorf("error removing %s code file: %w", kind, err)
+					}
+					return code, nil
+				}
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf("error reading %s code file: %w", kind, err)
+			}
+
+			if time.Now().After(deadline) {
+				return "", fmt.Errorf("timed out waiting for %s code in %s", kind, path)
+			}
+			time.Sleep(codePollInterval)
+		}
+	}
+}
+
+// loginSession is the part of a goinsta session the login flow uses, so
+// tests can stand in for Instagram
+type loginSession interface {
+	Login() error
+	// Login2FA completes two-factor login, generating the code from the
+	// TOTP seed when none is given
+	Login2FA(code ...string) error
+	// StartChallenge asks Instagram to send a security code
+	StartChallenge() error
+	SendChallengeCode(code string) error
+	OpenApp() error
+	Export(path string) error
+	// Instagram returns the underlying client
+	Instagram() *goinsta.Instagram
+}
+
+// goinstaSession is a loginSession backed by goinsta
+type goinstaSession struct {
+	insta *goinsta.Instagram
+}
+
+// newGoinstaSession creates a session for the configured credentials
+func newGoinstaSession(config *Configuration) loginSession {
+	insta := goinsta.New(config.Username, config.Password)
+	if config.TOTPSeed != "" {
+		insta.SetTOTPSeed(config.TOTPSeed)
+	}
+	return &goinstaSession{insta: insta}
+}
+
+func (s *goinstaSession) Login() error { return s.insta.Login() }
+
+func (s *goinstaSession) Login2FA(code ...string) error {
+	return s.insta.TwoFactorInfo.Login2FA(code...)
+}
+
+func (s *goinstaSession) StartChallenge() error {
+	challenge := s.insta.Challenge
+	if challenge == nil || challenge.ApiPath == "" {
+		return errors.New("no challenge path was returned")
+	}
+	return challenge.ProcessOld(challenge.ApiPath)
+}
+
+func (s *goinstaSession) SendChallengeCode(code string) error {
+	return s.insta.Challenge.SendSecurityCode(code)
+}
+
+func (s *goinstaSession) OpenApp() error { return s.insta.OpenApp() }
+
+func (s *goinstaSession) Export(path string) error { return s.insta.Export(path) }
+
+func (s *goinstaSession) Instagram() *goinsta.Instagram { return s.insta }
+
+// completeLogin finishes a login that stopped at a two-factor or challenge
+// step, returning loginErr unchanged for any other failure
+func (bot *InstagramBot) completeLogin(session loginSession, loginErr error) error {
+	switch {
+	case errors.Is(loginErr, goinsta.Err2FARequired):
+		bot.logger.Info("Two-factor authentication required")
+
+		// goinsta generates the code itself when a TOTP seed is configured
+		if bot.config().TOTPSeed != "" {
+			return session.Login2FA()
+		}
+
+		code, err := bot.codeProvider("two-factor")
+		if err != nil {
+			return err
+		}
+		return session.Login2FA(code)
+
+	case errors.Is(loginErr, goinsta.ErrChallengeRequired):
+		bot.logger.Info("Login challenge required")
+
+		if err := session.StartChallenge(); err != nil {
+			return fmt.Errorf("error starting challenge: %w", err)
+		}
+
+		code, err := bot.codeProvider("challenge")
+		if err != nil {
+			return err
+		}
+		if err := session.SendChallengeCode(code); err != nil {
+			return fmt.Errorf("error sending challenge code: %w", err)
+		}
+		return session.OpenApp()
+
+	default:
+		return loginErr
+	}
+}
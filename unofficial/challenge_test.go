@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// fakeSession is a loginSession whose Login fails with loginErr and which
+// records the steps taken to complete it
+type fakeSession struct {
+	loginErr      error
+	logins        int
+	twoFactorCode []string
+	challenges    int
+	challengeCode string
+	codeErr       error
+	exported      string
+}
+
+func (s *fakeSession) Login() error {
+	s.logins++
+	return s.loginErr
+}
+
+func (s *fakeSession) Login2FA(code ...string) error {
+	s.twoFactorCode = code
+	return nil
+}
+
+func (s *fakeSession) StartChallenge() error {
+	s.challenges++
+	return nil
+}
+
+func (s *fakeSession) SendChallengeCode(code string) error {
+	s.challengeCode = code
+	return s.codeErr
+}
+
+func (s *fakeSession) OpenApp() error { return nil }
+
+func (s *fakeSession) Export(path string) error {
+	s.exported = path
+	return nil
+}
+
+func (s *fakeSession) Instagram() *goinsta.Instagram {
+	return &goinsta.Instagram{Account: &goinsta.Account{ID: testAccountID}}
+}
+
+// useSession makes bot log in through session, answering code prompts
+// with code and recording the kinds asked for
+func useSession(bot *InstagramBot, session *fakeSession, code string) *[]string {
+	var asked []string
+	bot.newSession = func(*Configuration) loginSession { return session }
+	bot.codeProvider = func(kind string) (string, error) {
+		asked = append(asked, kind)
+		return code, nil
+	}
+	return &asked
+}
+
+func TestLoginCompletesChallenge(t *testing.T) {
+	config := newTestConfig(t)
+	bot, _ := newTestBot(t, config)
+	session := &fakeSession{loginErr: fmt.Errorf("login: %w", goinsta.ErrChallengeRequired)}
+	asked := useSession(bot, session, "123456")
+
+	if err := bot.Login(); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if session.challenges != 1 || session.challengeCode != "123456" {
+		t.Errorf("challenge started %d times with code %q, want once with 123456", session.challenges, session.challengeCode)
+	}
+	if len(*asked) != 1 || (*asked)[0] != "challenge" {
+		t.Errorf("asked for codes %q, want one challenge code", *asked)
+	}
+	if session.exported != config.ConfigPath {
+		t.Errorf("session exported to %q, want %q", session.exported, config.ConfigPath)
+	}
+	if !bot.metrics.LoggedIn.Load() {
+		t.Error("bot not marked as logged in")
+	}
+}
+
+func TestLoginFailsWhenChallengeCodeIsRejected(t *testing.T) {
+	bot, _ := newTestBot(t, newTestConfig(t))
+	session := &fakeSession{
+		loginErr: goinsta.ErrChallengeRequired,
+		codeErr:  errors.New("invalid code"),
+	}
+	useSession(bot, session, "000000")
+
+	if err := bot.Login(); err == nil {
+		t.Fatal("Login succeeded with a rejected code")
+	}
+	if session.exported != "" {
+		t.Error("session exported after a failed login")
+	}
+	if bot.metrics.LoggedIn.Load() {
+		t.Error("bot marked as logged in")
+	}
+}
+
+func TestLoginCompletesTwoFactor(t *testing.T) {
+	bot, _ := newTestBot(t, newTestConfig(t))
+	session := &fakeSession{loginErr: goinsta.Err2FARequired}
+	asked := useSession(bot, session, "654321")
+
+	if err := bot.Login(); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if len(session.twoFactorCode) != 1 || session.twoFactorCode[0] != "654321" {
+		t.Errorf("two-factor code = %q, want 654321", session.twoFactorCode)
+	}
+	if len(*asked) != 1 || (*asked)[0] != "two-factor" {
+		t.Errorf("asked for codes %q, want one two-factor code", *asked)
+	}
+}
+
+func TestLoginUsesTOTPSeedWithoutAsking(t *testing.T) {
+	config := newTestConfig(t)
+	config.TOTPSeed = "JBSWY3DPEHPK3PXP"
+	bot, _ := newTestBot(t, config)
+	session := &fakeSession{loginErr: goinsta.Err2FARequired}
+	asked := useSession(bot, session, "654321")
+
+	if err := bot.Login(); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if len(*asked) != 0 || len(session.twoFactorCode) != 0 {
+		t.Errorf("asked for %q and sent %q, want the code generated from the seed", *asked, session.twoFactorCode)
+	}
+}
+
+func TestLoginReturnsOtherErrors(t *testing.T) {
+	bot, _ := newTestBot(t, newTestConfig(t))
+	wrong := errors.New("bad password")
+	asked := useSession(bot, &fakeSession{loginErr: wrong}, "123456")
+
+	if err := bot.Login(); !errors.Is(err, wrong) {
+		t.Errorf("Login error = %v, want %v", err, wrong)
+	}
+	if len(*asked) != 0 {
+		t.Errorf("asked for codes %q on a plain failure", *asked)
+	}
+}
+
+func TestFileCodeProviderConsumesCode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "code.txt")
+	if err := os.WriteFile(path, []byte("123456\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := fileCodeProvider(path, time.Second)("challenge")
+	if err != nil || code != "123456" {
+		t.Fatalf("code = %q, %v, want 123456", code, err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("code file not removed after reading")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/Davincible/goinsta"
+)
+
+// lastSentTexts remembers the last message the bot sent in each thread
+type lastSentTexts struct {
+	mu    sync.Mutex
+	texts map[string]string
+}
+
+// newLastSentTexts creates an empty tracker
+func newLastSentTexts() *lastSentTexts {
+	return &lastSentTexts{texts: make(map[string]string)}
+}
+
+// Record stores text as the last message sent in the thread
+func (l *lastSentTexts) Record(threadID, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.texts[threadID] = text
+}
+
+// Last returns the last message sent in the thread since startup
+func (l *lastSentTexts) Last(threadID string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	text, ok := l.texts[threadID]
+	return text, ok
+}
+
+// isConsecutiveDuplicate reports whether text is identical to the last
+// message the bot sent in conv. Threads without a recorded message fall
+// back to the account's newest message in the thread history.
+func (bot *InstagramBot) isConsecutiveDuplicate(conv *goinsta.Conversation, text string) bool {
+	if last, ok := bot.lastSent.Last(conv.ID); ok {
+		return last == text
+	}
+
+	// Items are ordered newest first
+	for _, item := range conv.Items {
+		if item.UserID == bot.insta.Account.ID {
+			return item.Text == text
+		}
+	}
+	return false
+}
+
+// itemSet holds the IDs of inbox items the bot has acted on
+type itemSet struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+// newItemSet creates an empty set
+func newItemSet() *itemSet {
+	return &itemSet{ids: make(map[string]bool)}
+}
+
+// Add records the item
+func (s *itemSet) Add(itemID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[itemID] = true
+}
+
+// Has reports whether the item was recorded
+func (s *itemSet) Has(itemID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids[itemID]
+}
+
+// Remove forgets the item
+func (s *itemSet) Remove(itemID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ids, itemID)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+func TestProcessConversationAnswersNewestMessage(t *testing.T) {
+	config := newTestConfig(t)
+	config.ResponseRules = map[string]responder.Response{"price": {Text: "From $10."}}
+	bot, sender := newTestBot(t, config)
+
+	now := time.Now()
+	bot.processConversation(newTestConversation("t1", 42,
+		textItem("i2", 42, "what is the price?", now),
+		textItem("i1", 42, "hello", now.Add(-time.Minute)),
+	))
+
+	want := []string{"From $10."}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestSecondIdenticalReplyInThreadIsSuppressed(t *testing.T) {
+	config := newTestConfig(t)
+	config.SuppressConsecutiveDuplicates = true
+	bot, sender := newTestBot(t, config)
+
+	// Two members of a group thread get the same default reply in a row
+	conv := newTestConversation("g1", 41, textItem("i1", 41, "hello", time.Now()))
+	conv.IsGroup = true
+	bot.processConversation(conv)
+	conv.Items = append([]*goinsta.InboxItem{textItem("i2", 42, "hi there", time.Now())}, conv.Items...)
+	bot.processConversation(conv)
+
+	if got := sender.Texts(); len(got) != 1 {
+		t.Fatalf("sent %q, want the identical second reply suppressed", got)
+	}
+	if !bot.respondedUsers.HasResponded(42) {
+		t.Error("user whose reply was suppressed is re-evaluated every check")
+	}
+}
+
+func TestDuplicateDetectedFromThreadHistory(t *testing.T) {
+	config := newTestConfig(t)
+	config.SuppressConsecutiveDuplicates = true
+	bot, sender := newTestBot(t, config)
+
+	// After a restart the bot's newest message in the thread is the reply
+	now := time.Now()
+	bot.processConversation(newTestConversation("t1", 42,
+		textItem("i3", 42, "hello?", now),
+		textItem("i2", testAccountID, "Thanks for your message!", now.Add(-time.Minute)),
+		textItem("i1", testAccountID, "An older message", now.Add(-time.Hour)),
+	))
+
+	if got := sender.Texts(); len(got) != 0 {
+		t.Errorf("sent %q, want the duplicate suppressed", got)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// forwardTimeout bounds how long forwarding or alerting may hold up the bot
+const forwardTimeout = 10 * time.Second
+
+// UnmatchedMessage is an inbound message no response rule matched,
+// forwarded for human follow-up
+type UnmatchedMessage struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Text     string `json:"text"`
+	ThreadID string `json:"thread_id"`
+}
+
+// forwardUnmatched posts msg as JSON to the configured forward URL
+func (bot *InstagramBot) forwardUnmatched(msg UnmatchedMessage) error {
+	if err := postJSON(bot.config().UnmatchedForwardURL, msg); err != nil {
+		return fmt.Errorf("error forwarding unmatched message: %w", err)
+	}
+	return nil
+}
+
+// postJSON posts v as JSON to url, treating non-2xx responses as errors
+func postJSON(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling request body: %w", err)
+	}
+
+	client := &http.Client{Timeout: forwardTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+// forwardQueue is a fake human queue recording forwarded messages
+type forwardQueue struct {
+	mu       sync.Mutex
+	messages []UnmatchedMessage
+}
+
+func (q *forwardQueue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var msg UnmatchedMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages = append(q.messages, msg)
+}
+
+func (q *forwardQueue) Messages() []UnmatchedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]UnmatchedMessage(nil), q.messages...)
+}
+
+// newForwardingBot returns a bot forwarding unmatched messages to a fake queue
+func newForwardingBot(t *testing.T) (*InstagramBot, *fakeSender, *forwardQueue) {
+	queue := &forwardQueue{}
+	server := httptest.NewServer(queue)
+	t.Cleanup(server.Close)
+
+	config := newTestConfig(t)
+	config.ResponseRules = map[string]responder.Response{"price": {Text: "From $10."}}
+	config.UnmatchedForwardURL = server.URL
+	bot, sender := newTestBot(t, config)
+	return bot, sender, queue
+}
+
+func TestUnmatchedMessageIsForwarded(t *testing.T) {
+	bot, sender, queue := newForwardingBot(t)
+
+	item := textItem("i1", 42, "can I return my order?", time.Now())
+	bot.respondToMessage(newTestConversation("t1", 42, item), item, false)
+
+	messages := queue.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("forwarded %d messages, want 1", len(messages))
+	}
+	want := UnmatchedMessage{UserID: 42, Username: "alice", Text: "can I return my order?", ThreadID: "t1"}
+	if messages[0] != want {
+		t.Errorf("forwarded %+v, want %+v", messages[0], want)
+	}
+	if got := sender.Texts(); len(got) != 1 || got[0] != "Thanks for your message!" {
+		t.Errorf("sent %q, want the default response", got)
+	}
+}
+
+func TestMatchedMessageIsNotForwarded(t *testing.T) {
+	bot, _, queue := newForwardingBot(t)
+
+	item := textItem("i1", 42, "what is the price?", time.Now())
+	bot.respondToMessage(newTestConversation("t1", 42, item), item, false)
+
+	if messages := queue.Messages(); len(messages) != 0 {
+		t.Errorf("forwarded %+v, want nothing", messages)
+	}
+}
+
+func TestUnmatchedMessageForwardedOnceWhenReplyIsRetried(t *testing.T) {
+	bot, sender, queue := newForwardingBot(t)
+	sender.fail = func(string) error { return errors.New("send failed") }
+
+	item := textItem("i1", 42, "can I return my order?", time.Now())
+	conv := newTestConversation("t1", 42, item)
+	bot.respondToMessage(conv, item, false)
+	bot.respondToMessage(conv, item, false)
+
+	if messages := queue.Messages(); len(messages) != 1 {
+		t.Errorf("forwarded %d times, want once", len(messages))
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// JoinedThreads tracks group threads the bot has introduced itself in
+type JoinedThreads struct {
+	Threads map[string]time.Time `json:"threads"`
+	mu      sync.Mutex
+}
+
+// NewJoinedThreads initializes the joined threads tracker
+func NewJoinedThreads(filepath string) (*JoinedThreads, error) {
+	jt := &JoinedThreads{
+		Threads: make(map[string]time.Time),
+	}
+
+	// Load previously joined threads if file exists
+	if _, err := os.Stat(filepath); err == nil {
+		data, err := os.ReadFile(filepath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading joined threads file: %w", err)
+		}
+
+		var loadedThreads map[string]time.Time
+		if err := json.Unmarshal(data, &loadedThreads); err != nil {
+			return nil, fmt.Errorf("error unmarshaling joined threads: %w", err)
+		}
+		jt.Threads = loadedThreads
+	}
+
+	return jt, nil
+}
+
+// HasIntroduced checks if the bot has already introduced itself in a thread
+func (jt *JoinedThreads) HasIntroduced(threadID string) bool {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	_, exists := jt.Threads[threadID]
+	return exists
+}
+
+// MarkIntroduced records that the bot has introduced itself in a thread
+func (jt *JoinedThreads) MarkIntroduced(threadID string) {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	jt.Threads[threadID] = time.Now()
+}
+
+// Save persists the joined threads data to file
+func (jt *JoinedThreads) Save(filepath string) error {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+
+	data, err := json.MarshalIndent(jt.Threads, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling joined threads: %w", err)
+	}
+
+	if err := os.WriteFile(filepath, data, 0644); err != nil {
+		return fmt.Errorf("error writing joined threads file: %w", err)
+	}
+
+	return nil
+}
+
+// isAddedToThread reports whether the conversation contains the event of
+// the given account being added to the group
+func isAddedToThread(conv *goinsta.Conversation, username string) bool {
+	for _, item := range conv.Items {
+		if item.Type != "action_log" || item.ActionLog == nil {
+			continue
+		}
+
+		// Instagram renders the event as "<inviter> added you to the group."
+		// or, for some clients, with the account's username instead of "you"
+		words := strings.FieldsFunc(strings.ToLower(item.ActionLog.Description), func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		for i, word := range words {
+			if word != "added" {
+				continue
+			}
+			for _, added := range words[i+1:] {
+				added = strings.TrimLeft(strings.TrimRight(added, ".!"), "@")
+				if added == "you" || added == strings.ToLower(username) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// introduceToGroup sends the group join response once per group thread
+func (bot *InstagramBot) introduceToGroup(conv *goinsta.Conversation) {
+	if bot.config().GroupJoinResponse == "" || bot.joinedThreads.HasIntroduced(conv.ID) {
+		return
+	}
+
+	if !isAddedToThread(conv, bot.insta.Account.Username) {
+		return
+	}
+
+	bot.logger.Info("Introducing to group thread", "conversation_id", conv.ID, "thread_title", conv.Title)
+
+	if !bot.throttle.Wait(bot.config().GroupJoinResponse) {
+		bot.logger.Warn("Daily reply budget reached, not introducing to group thread", "conversation_id", conv.ID)
+		return
+	}
+
+	// Introductions are paced like replies but don't use up the budget
+	err := bot.send(conv, bot.config().GroupJoinResponse)
+	bot.limiter.Observe(err)
+	bot.throttle.Refund()
+	if err != nil {
+		bot.metrics.SendErrors.Add(1)
+		bot.logger.Error("Error sending group join response", "conversation_id", conv.ID, "error", err)
+		return
+	}
+
+	bot.lastSent.Record(conv.ID, bot.config().GroupJoinResponse)
+	bot.metrics.MessagesSent.Add(1)
+	bot.joinedThreads.MarkIntroduced(conv.ID)
+	bot.logger.Info("Sent group join response", "conversation_id", conv.ID, "thread_title", conv.Title, "response", bot.config().GroupJoinResponse)
+}
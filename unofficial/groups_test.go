@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Davincible/goinsta"
+)
+
+// actionLogItem returns a thread event such as a group join. goinsta's
+// action log type is unexported, so the item is decoded from JSON.
+func actionLogItem(id, description string) *goinsta.InboxItem {
+	data, err := json.Marshal(map[string]interface{}{
+		"item_id":    id,
+		"item_type":  "action_log",
+		"action_log": map[string]string{"description": description},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var item goinsta.InboxItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		panic(err)
+	}
+	return &item
+}
+
+func TestIsAddedToThread(t *testing.T) {
+	tests := []struct {
+		description string
+		want        bool
+	}{
+		{"alice added you to the group.", true},
+		{"alice added bob, you and 2 others to the group.", true},
+		{"alice added shop to the group.", true},
+		{"alice added @shop.", true},
+		{"alice added youssef to the group.", false},
+		{"alice added young.shop to the group.", false},
+		{"alice added myshop to the group.", false},
+		{"you added alice to the group.", false},
+		{"alice named the group Friends.", false},
+	}
+	for _, tt := range tests {
+		conv := &goinsta.Conversation{Items: []*goinsta.InboxItem{actionLogItem("i1", tt.description)}}
+		if got := isAddedToThread(conv, "shop"); got != tt.want {
+			t.Errorf("isAddedToThread(%q) = %v, want %v", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestIntroduceToGroupOncePerThread(t *testing.T) {
+	config := newTestConfig(t)
+	config.GroupJoinResponse = "Hi all, I'm the shop's assistant!"
+	config.JoinedThreadsFile = filepath.Join(t.TempDir(), "joined.json")
+	bot, sender := newTestBot(t, config)
+
+	conv := newTestConversation("g1", 42, actionLogItem("i1", "alice added you to the group."))
+	conv.IsGroup = true
+	bot.processConversation(conv)
+	bot.processConversation(conv)
+
+	if got := sender.Texts(); len(got) != 1 || got[0] != config.GroupJoinResponse {
+		t.Fatalf("sent %q, want one introduction", got)
+	}
+
+	// The introduction is remembered across restarts
+	if err := bot.joinedThreads.Save(config.JoinedThreadsFile); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	restarted, err := NewJoinedThreads(config.JoinedThreadsFile)
+	if err != nil {
+		t.Fatalf("NewJoinedThreads: %v", err)
+	}
+	if !restarted.HasIntroduced("g1") {
+		t.Error("introduction not persisted")
+	}
+}
+
+func TestIntroduceToGroupIgnoresOtherThreads(t *testing.T) {
+	config := newTestConfig(t)
+	config.GroupJoinResponse = "Hi all!"
+	config.JoinedThreadsFile = filepath.Join(t.TempDir(), "joined.json")
+	bot, sender := newTestBot(t, config)
+
+	conv := newTestConversation("g1", 42, actionLogItem("i1", "alice added youssef to the group."))
+	conv.IsGroup = true
+	bot.introduceToGroup(conv)
+
+	if got := sender.Texts(); len(got) != 0 {
+		t.Errorf("sent %q, want nothing", got)
+	}
+}
+
+func TestGroupJoinResponseRequiresJoinedThreadsFile(t *testing.T) {
+	config := newTestConfig(t)
+	config.GroupJoinResponse = "Hi all!"
+
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "joined_threads_file is required") {
+		t.Errorf("Validate() = %v, want joined_threads_file required", err)
+	}
+}
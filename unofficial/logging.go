@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log formats accepted by log_format
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// parseLogLevel converts log_level into a slog level, defaulting to info
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// newLogger opens the log file and returns a logger writing to it and to
+// stdout in the configured format. The level can be changed later through
+// the returned LevelVar.
+func newLogger(config *Configuration) (*slog.Logger, *slog.LevelVar, error) {
+	logFile, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening log file: %w", err)
+	}
+
+	level := new(slog.LevelVar)
+	if l, err := parseLogLevel(config.LogLevel); err == nil {
+		level.Set(l)
+	}
+
+	w := io.MultiWriter(os.Stdout, logFile)
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if strings.ToLower(config.LogFormat) == LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	return slog.New(handler).With("account", config.Username), level, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepliesAreLoggedAsJSON(t *testing.T) {
+	config := newTestConfig(t)
+	config.LogFormat = LogFormatJSON
+	config.LogLevel = "info"
+	bot, _ := newTestBot(t, config)
+
+	bot.processConversation(newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now())))
+
+	data, err := os.ReadFile(config.LogFile)
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	var sent map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		if record["msg"] == "Sent auto-reply" {
+			sent = record
+		}
+	}
+	if sent == nil {
+		t.Fatalf("no Sent auto-reply record in %s", data)
+	}
+
+	want := map[string]interface{}{
+		"level":           "INFO",
+		"account":         "shop",
+		"conversation_id": "t1",
+		"user_id":         float64(42),
+		"response":        "Thanks for your message!",
+	}
+	for key, value := range want {
+		if sent[key] != value {
+			t.Errorf("record %s = %v, want %v", key, sent[key], value)
+		}
+	}
+}
+
+func TestParseLogLevelRejectsUnknownLevel(t *testing.T) {
+	if _, err := parseLogLevel("chatty"); err == nil {
+		t.Error("no error for an unknown level")
+	}
+	if level, err := parseLogLevel(""); err != nil || level.String() != "INFO" {
+		t.Errorf("empty level = %v, %v, want INFO", level, err)
+	}
+}
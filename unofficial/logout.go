@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// defaultLoggedOutCooldown is used when no cooldown is configured
+const defaultLoggedOutCooldown = time.Hour
+
+// Alert is posted to the alert URL when the bot needs attention
+type Alert struct {
+	Account string    `json:"account"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// isLoggedOut reports whether err means the session was invalidated, for
+// example by logging out from another device, as opposed to a transient error
+func isLoggedOut(err error) bool {
+	return errors.Is(err, goinsta.ErrLoggedOut) || errors.Is(err, goinsta.ErrLoginRequired)
+}
+
+// handleLoggedOut makes a single attempt to log in again after the session
+// was invalidated. If that fails the bot alerts and stays idle for the
+// cooldown instead of retrying every check.
+func (bot *InstagramBot) handleLoggedOut(cause error) {
+	bot.metrics.LoggedIn.Store(false)
+	bot.logger.Warn("Session invalidated, logging in again", "error", cause)
+
+	err := bot.loginWithPassword()
+	if err == nil {
+		bot.logger.Info("Logged in again after remote logout")
+		return
+	}
+
+	cooldown := defaultLoggedOutCooldown
+	if minutes := bot.config().LoggedOutCooldownMinutes; minutes > 0 {
+		cooldown = time.Duration(minutes) * time.Minute
+	}
+	bot.cooldownUntil = time.Now().Add(cooldown)
+
+	bot.alert(fmt.Sprintf("Logged out remotely and logging in again failed: %v. Pausing until %s.",
+		err, bot.cooldownUntil.Format(time.RFC3339)))
+}
+
+// alert logs a message that needs an operator's attention and posts it to
+// the alert URL if one is configured
+func (bot *InstagramBot) alert(message string) {
+	bot.logger.Error("Alert", "alert", message)
+
+	alertURL := bot.config().AlertURL
+	if alertURL == "" {
+		return
+	}
+
+	err := postJSON(alertURL, Alert{
+		Account: bot.config().Username,
+		Message: message,
+		Time:    time.Now(),
+	})
+	if err != nil {
+		bot.logger.Error("Error sending alert", "error", err)
+	}
+}
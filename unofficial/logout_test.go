@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// alertReceiver records the alerts posted to it
+type alertReceiver struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (a *alertReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var alert Alert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.alerts = append(a.alerts, alert)
+}
+
+func (a *alertReceiver) Alerts() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Alert(nil), a.alerts...)
+}
+
+// newLoggedOutBot returns a bot alerting to a fake receiver whose next
+// login fails with loginErr
+func newLoggedOutBot(t *testing.T, loginErr error) (*InstagramBot, *fakeSession, *alertReceiver) {
+	t.Helper()
+	receiver := &alertReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	config := newTestConfig(t)
+	config.AlertURL = server.URL
+	config.LoggedOutCooldownMinutes = 30
+	bot, _ := newTestBot(t, config)
+	bot.metrics.LoggedIn.Store(true)
+
+	session := &fakeSession{loginErr: loginErr}
+	useSession(bot, session, "")
+	return bot, session, receiver
+}
+
+func TestLoggedOutTriesOneLoginThenCoolsDownAndAlerts(t *testing.T) {
+	bot, session, receiver := newLoggedOutBot(t, errors.New("bad password"))
+
+	before := time.Now()
+	bot.handleLoggedOut(fmt.Errorf("syncing inbox: %w", goinsta.ErrLoggedOut))
+
+	if session.logins != 1 {
+		t.Errorf("tried to log in %d times, want once", session.logins)
+	}
+	if bot.metrics.LoggedIn.Load() {
+		t.Error("bot still reported as logged in")
+	}
+	wantUntil := before.Add(30 * time.Minute)
+	if bot.cooldownUntil.Before(wantUntil) || bot.cooldownUntil.After(wantUntil.Add(time.Minute)) {
+		t.Errorf("cooldown until %s, want about %s", bot.cooldownUntil, wantUntil)
+	}
+
+	alerts := receiver.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("received %d alerts, want 1", len(alerts))
+	}
+	if alerts[0].Account != "shop" || !strings.Contains(alerts[0].Message, "bad password") {
+		t.Errorf("alert = %+v, want one for shop naming the login error", alerts[0])
+	}
+
+	// Checks during the cooldown neither sync nor log in again
+	bot.checkMessages()
+	if got := bot.metrics.CheckCycles.Load(); got != 0 {
+		t.Errorf("ran %d checks during the cooldown", got)
+	}
+	if session.logins != 1 {
+		t.Errorf("tried to log in %d times during the cooldown", session.logins)
+	}
+}
+
+func TestLoggedOutRecoversWhenLoginSucceeds(t *testing.T) {
+	bot, session, receiver := newLoggedOutBot(t, nil)
+
+	bot.handleLoggedOut(goinsta.ErrLoggedOut)
+
+	if session.logins != 1 || !bot.metrics.LoggedIn.Load() {
+		t.Errorf("logins = %d, logged in = %v; want one successful login", session.logins, bot.metrics.LoggedIn.Load())
+	}
+	if !bot.cooldownUntil.IsZero() {
+		t.Errorf("cooldown set to %s after a successful login", bot.cooldownUntil)
+	}
+	if alerts := receiver.Alerts(); len(alerts) != 0 {
+		t.Errorf("received alerts %+v, want none", alerts)
+	}
+}
+
+func TestIsLoggedOut(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("sync: %w", goinsta.ErrLoggedOut), true},
+		{goinsta.ErrLoginRequired, true},
+		{goinsta.ErrTooManyRequests, false},
+		{errors.New("connection reset"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isLoggedOut(tt.err); got != tt.want {
+			t.Errorf("isLoggedOut(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -4,24 +4,118 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
-	"strings"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Davincible/goinsta"
+
+	"projects/instagram_replayer_bot/responder"
 )
 
+// defaultConcurrency is the number of conversations processed in parallel
+// when no concurrency is configured
+const defaultConcurrency = 2
+
+// defaultPruneEveryChecks is how many check cycles pass between prunes of
+// the responded users when no interval is configured
+const defaultPruneEveryChecks = 60
+
 // Configuration holds all app settings
 type Configuration struct {
-	Username           string            `json:"username"`
-	Password           string            `json:"password"`
-	ConfigPath         string            `json:"config_path"`
-	CheckInterval      int               `json:"check_interval_seconds"`
-	ResponseRules      map[string]string `json:"response_rules"`
-	DefaultResponse    string            `json:"default_response"`
-	LogFile            string            `json:"log_file"`
-	RespondedUsersFile string            `json:"responded_users_file"`
+	// Config holds the response rules shared with the webhook server
+	responder.Config
+
+	Username                 string   `json:"username"`
+	Password                 string   `json:"password"`
+	ConfigPath               string   `json:"config_path"`
+	CheckInterval            int      `json:"check_interval_seconds"`
+	LogFile                  string   `json:"log_file"`
+	RespondedUsersFile       string   `json:"responded_users_file"`
+	GroupJoinResponse        string   `json:"group_join_response"`
+	JoinedThreadsFile        string   `json:"joined_threads_file"`
+	SendRatePerMinute        int      `json:"send_rate_per_minute"`
+	DailyReplyBudget         int      `json:"daily_reply_budget"`
+	SimulateTyping           bool     `json:"simulate_typing"`
+	SafeMode                 bool     `json:"safe_mode"`
+	SafeModeAllow            []string `json:"safe_mode_allow"`
+	VanishModePolicy         string   `json:"vanish_mode_policy"`
+	TOTPSeed                 string   `json:"totp_seed"`
+	TwoFactorCodeSource      string   `json:"two_factor_code_source"`
+	TwoFactorCodeFile        string   `json:"two_factor_code_file"`
+	TwoFactorCodeTimeout     int      `json:"two_factor_code_timeout_seconds"`
+	UnmatchedForwardURL      string   `json:"unmatched_forward_url"`
+	SuppressDefaultOnForward bool     `json:"suppress_default_on_forward"`
+
+	// Schedule gates auto-replies during working hours
+	Schedule *Schedule `json:"schedule"`
+
+	// Concurrency is the number of conversations processed in parallel
+	Concurrency int `json:"concurrency"`
+
+	// MetricsPort serves /healthz and /metrics when non-zero
+	MetricsPort int `json:"metrics_port"`
+
+	// SuppressConsecutiveDuplicates skips a reply identical to the last
+	// message the bot sent in the same thread
+	SuppressConsecutiveDuplicates bool `json:"suppress_consecutive_duplicates"`
+
+	// PendingRepliesFile persists replies waiting to be sent across restarts
+	PendingRepliesFile string `json:"pending_replies_file"`
+
+	// SeenUsersFile persists when users first messaged the account
+	SeenUsersFile string `json:"seen_users_file"`
+
+	// MediaResponse answers photos, videos, voice notes and shared posts,
+	// StoryReplyResponse answers replies to the account's stories. When
+	// unset, any text sent with the item goes through the response rules.
+	MediaResponse      string `json:"media_response"`
+	StoryReplyResponse string `json:"story_reply_response"`
+
+	// ForwardedMessageResponse answers shared posts, reels, stories and
+	// profiles. goinsta doesn't report whether an item was forwarded, so
+	// every item of these share types counts, and for them this replaces
+	// MediaResponse. Photos, videos and voice notes keep MediaResponse.
+	ForwardedMessageResponse string `json:"forwarded_message_response"`
+
+	// RespondedUsersRetentionHours forgets users this long after their
+	// auto-reply, so they may be answered again; 0 keeps them forever.
+	// Messages older than the window are then never answered, so a
+	// forgotten user isn't answered twice for the same message. Pruning
+	// runs every PruneEveryChecks check cycles.
+	RespondedUsersRetentionHours int `json:"responded_users_retention_hours"`
+	PruneEveryChecks             int `json:"prune_every_checks"`
+
+	// TwoPhaseReply acknowledges messages before sending the detailed answer
+	TwoPhaseReply *TwoPhaseReply `json:"two_phase_reply"`
+
+	// LoggedOutCooldownMinutes is how long the bot stays idle when it was
+	// logged out remotely and logging in again failed
+	LoggedOutCooldownMinutes int `json:"logged_out_cooldown_minutes"`
+	// AlertURL receives a JSON POST when the bot needs attention
+	AlertURL string `json:"alert_url"`
+
+	// LogLevel is one of debug, info, warn or error (default info).
+	// LogFormat is "text" or "json"; logs go to stdout and LogFile.
+	LogLevel  string `json:"log_level"`
+	LogFormat string `json:"log_format"`
+
+	// Accounts runs one bot per entry in the same process. Each entry is
+	// laid over the settings above, so it only needs what differs, such
+	// as credentials, config_path and the state files. Maps given in an
+	// entry, like response_rules, replace the shared ones entirely.
+	Accounts []json.RawMessage `json:"accounts"`
+}
+
+// TwoPhaseReply configures a quick acknowledgement such as "Let me check
+// that for you..." sent before the detailed answer. Outside of working
+// hours only; the in-hours response is sent on its own.
+type TwoPhaseReply struct {
+	Enabled bool   `json:"enabled"`
+	AckText string `json:"ack_text"`
 }
 
 // RespondedUsers tracks users that have received auto-replies
@@ -68,6 +162,23 @@ func (ru *RespondedUsers) MarkResponded(userID int64) {
 	ru.Users[userID] = time.Now()
 }
 
+// Prune drops users who received their auto-reply longer than olderThan
+// ago, returning how many were removed
+func (ru *RespondedUsers) Prune(olderThan time.Duration) int {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for userID, respondedAt := range ru.Users {
+		if respondedAt.Before(cutoff) {
+			delete(ru.Users, userID)
+			removed++
+		}
+	}
+	return removed
+}
+
 // Save persists the responded users data to file
 func (ru *RespondedUsers) Save(filepath string) error {
 	ru.mu.Lock()
@@ -87,70 +198,186 @@ func (ru *RespondedUsers) Save(filepath string) error {
 
 // InstagramBot represents the auto-reply bot
 type InstagramBot struct {
-	insta          *goinsta.Instagram
-	config         *Configuration
+	insta         *goinsta.Instagram
+	cfg           atomic.Pointer[Configuration]
+	engine        atomic.Pointer[responder.ResponseEngine]
+	cooldownUntil time.Time
+	// checkInterval is the check interval in seconds Start is running with
+	checkInterval  atomic.Int64
 	respondedUsers *RespondedUsers
-	logger         *log.Logger
+	joinedThreads  *JoinedThreads
+	throttle       *sendThrottle
+	codeProvider   CodeProvider
+	metrics        *Metrics
+	limiter        *adaptiveLimiter
+	lastSent       *lastSentTexts
+	replyQueue     *ReplyQueue
+	seenUsers      *SeenUsers
+	logger         *slog.Logger
+	logLevel       *slog.LevelVar
+
+	// acked and forwarded hold the items whose two-phase acknowledgement
+	// or unmatched forward went out but whose answer hasn't yet
+	acked     *itemSet
+	forwarded *itemSet
+
+	// heldForVanish holds messages answered once vanish mode ends
+	heldForVanish *heldMessages
+
+	// send delivers a message to a thread, replaced in tests
+	send func(conv *goinsta.Conversation, text string) error
+	// newSession creates a session to log in with, replaced in tests
+	newSession func(config *Configuration) loginSession
 }
 
 // NewInstagramBot creates a new Instagram bot instance
 func NewInstagramBot(config *Configuration) (*InstagramBot, error) {
 	// Set up logging
-	logFile, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	logger, logLevel, err := newLogger(config)
 	if err != nil {
-		return nil, fmt.Errorf("error opening log file: %w", err)
+		return nil, err
 	}
 
-	logger := log.New(logFile, "INSTAGRAM-BOT: ", log.LstdFlags|log.Lshortfile)
+	// Clamp aggressive settings before anything reads them
+	config.ApplySafeMode()
 
 	// Initialize responded users tracker
 	respondedUsers, err := NewRespondedUsers(config.RespondedUsersFile)
 	if err != nil {
-		logger.Printf("Error initializing responded users: %v", err)
+		logger.Error("Error initializing responded users", "error", err)
 		// Continue even if there's an error loading previous users
 	}
 
-	return &InstagramBot{
-		config:         config,
+	// Initialize joined group threads tracker
+	joinedThreads, err := NewJoinedThreads(config.JoinedThreadsFile)
+	if err != nil {
+		logger.Error("Error initializing joined threads", "error", err)
+		joinedThreads = &JoinedThreads{Threads: make(map[string]time.Time)}
+	}
+
+	// Initialize first-seen tracker for returning user rules
+	seenUsers, err := NewSeenUsers(config.SeenUsersFile)
+	if err != nil {
+		logger.Error("Error initializing seen users", "error", err)
+		seenUsers = &SeenUsers{Users: make(map[int64]time.Time)}
+	}
+
+	// Restore replies that were still queued when the bot last stopped
+	replyQueue, err := NewReplyQueue(config.PendingRepliesFile)
+	if err != nil {
+		logger.Error("Error initializing reply queue", "error", err)
+		replyQueue = &ReplyQueue{path: config.PendingRepliesFile}
+	}
+
+	// Publish counters at /debug/vars
+	metrics := &Metrics{}
+	registerMetrics(config.ConfigPath, metrics)
+
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	bot := &InstagramBot{
 		respondedUsers: respondedUsers,
+		joinedThreads:  joinedThreads,
+		throttle:       newSendThrottle(config),
+		codeProvider:   newCodeProvider(config),
+		metrics:        metrics,
+		limiter:        newAdaptiveLimiter(concurrency),
+		lastSent:       newLastSentTexts(),
+		replyQueue:     replyQueue,
+		seenUsers:      seenUsers,
 		logger:         logger,
-	}, nil
+		logLevel:       logLevel,
+		acked:          newItemSet(),
+		forwarded:      newItemSet(),
+		heldForVanish:  newHeldMessages(),
+		send:           (*goinsta.Conversation).Send,
+		newSession:     newGoinstaSession,
+	}
+	bot.cfg.Store(config)
+	bot.engine.Store(bot.newEngine(config))
+
+	return bot, nil
+}
+
+// config returns the current configuration, which may be swapped by a reload
+func (bot *InstagramBot) config() *Configuration {
+	return bot.cfg.Load()
 }
 
 // Login authenticates with Instagram
 func (bot *InstagramBot) Login() error {
 
 	// Try to import existing session
-	if _, err := os.Stat(bot.config.ConfigPath); err == nil {
-		bot.logger.Println("Importing existing Instagram session")
-		bot.insta, err = goinsta.Import(bot.config.ConfigPath)
+	if _, err := os.Stat(bot.config().ConfigPath); err == nil {
+		bot.logger.Info("Importing existing Instagram session", "path", bot.config().ConfigPath)
+		bot.insta, err = goinsta.Import(bot.config().ConfigPath)
 		if err != nil {
-			bot.logger.Printf("Failed to import session: %v. Trying to login...", err)
+			bot.logger.Warn("Failed to import session, trying to login", "error", err)
 		} else {
+			bot.metrics.LoggedIn.Store(true)
 			return nil
 		}
 	}
 
 	// Create new session if import failed
-	bot.insta = goinsta.New(bot.config.Username, bot.config.Password)
-	if err := bot.insta.Login(); err != nil {
-		return fmt.Errorf("login failed: %w", err)
+	return bot.loginWithPassword()
+}
+
+// loginWithPassword creates a new session from the configured credentials
+func (bot *InstagramBot) loginWithPassword() error {
+	session := bot.newSession(bot.config())
+	bot.insta = session.Instagram()
+	if err := session.Login(); err != nil {
+		// Complete two-factor or challenge steps with a verification code
+		if err := bot.completeLogin(session, err); err != nil {
+			return fmt.Errorf("login failed: %w", err)
+		}
 	}
 
 	// Export session for future use
-	if err := bot.insta.Export(bot.config.ConfigPath); err != nil {
+	if err := session.Export(bot.config().ConfigPath); err != nil {
 		return fmt.Errorf("failed to export session: %w", err)
 	}
 
-	bot.logger.Println("Login successful")
+	bot.metrics.LoggedIn.Store(true)
+	bot.logger.Info("Login successful")
 	return nil
 }
 
+// Run logs in and processes messages until the bot stops. Errors are
+// logged rather than fatal so other accounts keep running.
+func (bot *InstagramBot) Run() {
+	// Set up cleanup on exit
+	defer bot.Cleanup()
+	defer bot.recoverPanic("run")
+
+	// Login to Instagram
+	if err := bot.Login(); err != nil {
+		bot.logger.Error("Error logging in", "error", err)
+		return
+	}
+
+	bot.logger.Info("Bot started")
+
+	// Expose health and metrics endpoints
+	bot.StartMetricsServer()
+
+	// Pick up config changes without restarting
+	go bot.WatchConfig(configFile)
+
+	// Start processing messages
+	bot.Start()
+}
+
 // Start begins the auto-reply process
 func (bot *InstagramBot) Start() {
-	log.Println("Starting Instagram auto-reply bot")
+	bot.logger.Info("Starting Instagram auto-reply bot", "check_interval_seconds", bot.config().CheckInterval)
 
-	ticker := time.NewTicker(time.Duration(bot.config.CheckInterval) * time.Second)
+	bot.checkInterval.Store(int64(bot.config().CheckInterval))
+	ticker := time.NewTicker(time.Duration(bot.config().CheckInterval) * time.Second)
 	defer ticker.Stop()
 
 	// Initial check on startup
@@ -158,169 +385,444 @@ func (bot *InstagramBot) Start() {
 
 	for range ticker.C {
 		bot.checkMessages()
+		bot.followCheckInterval(ticker)
+	}
+}
+
+// followCheckInterval resets ticker when a reload changed
+// check_interval_seconds
+func (bot *InstagramBot) followCheckInterval(ticker *time.Ticker) {
+	interval := bot.config().CheckInterval
+	if interval <= 0 || int64(interval) == bot.checkInterval.Load() {
+		return
 	}
+	bot.checkInterval.Store(int64(interval))
+	ticker.Reset(time.Duration(interval) * time.Second)
+	bot.logger.Info("Check interval changed", "check_interval_seconds", interval)
 }
 
 // checkMessages checks for new direct messages and responds
 func (bot *InstagramBot) checkMessages() {
-	log.Println("Checking for new messages")
+	// A panic skips this check, the next one starts afresh
+	defer bot.recoverPanic("check")
+
+	// Stay idle after a failed re-login
+	if time.Now().Before(bot.cooldownUntil) {
+		bot.logger.Warn("Logged out, skipping check", "until", bot.cooldownUntil)
+		return
+	}
+
+	bot.logger.Debug("Checking for new messages")
+	bot.metrics.CheckCycles.Add(1)
 
 	// Get inbox
 	inbox := bot.insta.Inbox
-	if err := inbox.Sync(); err != nil {
-		bot.logger.Printf("Error syncing inbox: %v", err)
+	err := inbox.Sync()
+	bot.limiter.Observe(err)
+	if err != nil {
+		bot.metrics.SyncErrors.Add(1)
+		bot.logger.Error("Error syncing inbox", "error", err)
+		if isLoggedOut(err) {
+			bot.handleLoggedOut(err)
+		}
 		return
 	}
+	bot.metrics.LastSync.Store(time.Now().Unix())
 
-	log.Printf("Found %d conversations", len(inbox.Conversations))
+	bot.logger.Debug("Synced inbox", "conversations", len(inbox.Conversations))
 
-	// Process pending conversations
-	pending := inbox.Conversations
+	// Message requests from users the account doesn't follow
+	var pending []*goinsta.Conversation
 	if err := inbox.SyncPending(); err != nil {
-		log.Printf("Error syncing pending inbox: %v", err)
+		bot.limiter.Observe(err)
+		bot.metrics.SyncErrors.Add(1)
+		bot.logger.Error("Error syncing pending inbox", "error", err)
 	} else {
-		log.Println("Found pending conversations: ", pending)
-		bot.processConversations(pending)
+		pending = inbox.Pending
 	}
 
-	log.Println("Checking regular inbox")
+	bot.processInbox(inbox.Conversations, pending)
 
-	// Process regular inbox
-	bot.processConversations(inbox.Conversations)
+	bot.pruneRespondedUsers()
 
 	// Save responded users
-	if err := bot.respondedUsers.Save(bot.config.RespondedUsersFile); err != nil {
-		bot.logger.Printf("Error saving responded users: %v", err)
+	if err := bot.respondedUsers.Save(bot.config().RespondedUsersFile); err != nil {
+		bot.logger.Error("Error saving responded users", "error", err)
+	}
+
+	// Save joined group threads
+	if bot.config().JoinedThreadsFile != "" {
+		if err := bot.joinedThreads.Save(bot.config().JoinedThreadsFile); err != nil {
+			bot.logger.Error("Error saving joined threads", "error", err)
+		}
+	}
+
+	// Save seen users
+	if bot.config().SeenUsersFile != "" {
+		if err := bot.seenUsers.Save(bot.config().SeenUsersFile); err != nil {
+			bot.logger.Error("Error saving seen users", "error", err)
+		}
 	}
 }
 
-// processConversations handles multiple conversations
+// pruneRespondedUsers periodically drops responded users past the
+// retention window to keep the store from growing forever
+func (bot *InstagramBot) pruneRespondedUsers() {
+	retention := bot.config().RespondedUsersRetentionHours
+	if retention <= 0 {
+		return
+	}
+
+	every := int64(bot.config().PruneEveryChecks)
+	if every <= 0 {
+		every = defaultPruneEveryChecks
+	}
+	if bot.metrics.CheckCycles.Load()%every != 0 {
+		return
+	}
+
+	if removed := bot.respondedUsers.Prune(time.Duration(retention) * time.Hour); removed > 0 {
+		bot.logger.Info("Pruned responded users", "removed", removed, "retention_hours", retention)
+	}
+}
+
+// beforeRetention reports whether t is older than the responded users
+// retention window, if one is configured
+func (bot *InstagramBot) beforeRetention(t time.Time) bool {
+	retention := bot.config().RespondedUsersRetentionHours
+	if retention <= 0 {
+		return false
+	}
+	return t.Before(time.Now().Add(-time.Duration(retention) * time.Hour))
+}
+
+// processInbox answers the pending and regular inbox, handling each
+// conversation once even if it's listed in both
+func (bot *InstagramBot) processInbox(regular, pending []*goinsta.Conversation) {
+	// Finish replies left over from the last run before answering anyone new
+	bot.dispatchRestoredReplies(pending, regular)
+
+	seen := make(map[string]bool)
+	unique := func(conversations []*goinsta.Conversation) []*goinsta.Conversation {
+		var list []*goinsta.Conversation
+		for _, conv := range conversations {
+			if !seen[conv.ID] {
+				seen[conv.ID] = true
+				list = append(list, conv)
+			}
+		}
+		return list
+	}
+
+	pending = unique(pending)
+	bot.logger.Debug("Checking pending inbox", "conversations", len(pending))
+	bot.processConversations(pending)
+
+	regular = unique(regular)
+	bot.logger.Debug("Checking regular inbox", "conversations", len(regular))
+	bot.processConversations(regular)
+}
+
+// processConversations handles multiple conversations on a bounded pool of
+// workers, returning once every conversation has been processed. The
+// adaptive limiter lowers how many workers run at once while Instagram is
+// rate limiting.
 func (bot *InstagramBot) processConversations(conversations []*goinsta.Conversation) {
-	for i := range conversations {
-		conv := conversations[i]
-		log.Printf("Processing conversation with %s (%d)", conv.Inviter.Username, conv.Inviter.ID)
-		bot.processConversation(conv)
+	workers := bot.config().Concurrency
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+	if workers > len(conversations) {
+		workers = len(conversations)
+	}
+
+	jobs := make(chan *goinsta.Conversation)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for conv := range jobs {
+				bot.handleConversation(conv)
+			}
+		}()
+	}
+
+	for _, conv := range conversations {
+		jobs <- conv
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// handleConversation processes conv within the concurrency limit. A panic
+// only abandons this conversation.
+func (bot *InstagramBot) handleConversation(conv *goinsta.Conversation) {
+	bot.limiter.Acquire()
+	defer bot.limiter.Release()
+	defer bot.recoverPanic("conversation " + conv.ID)
+
+	bot.logger.Debug("Processing conversation", "conversation_id", conv.ID)
+	bot.processConversation(conv)
+}
+
+// recoverPanic logs a panic in task instead of letting it crash the
+// process and every other account with it. It must be deferred.
+func (bot *InstagramBot) recoverPanic(task string) {
+	if r := recover(); r != nil {
+		bot.logger.Error("Recovered from panic", "task", task, "panic", r, "stack", string(debug.Stack()))
 	}
 }
 
 // processConversation handles a single conversation
 func (bot *InstagramBot) processConversation(conv *goinsta.Conversation) {
-
-	log.Printf("Checking unread messages in conversation with %s (%d)", conv.Inviter.Username, conv.Inviter.ID)
+	bot.metrics.ConversationsProcessed.Add(1)
 
 	// Get all items in the conversation
 	if err := conv.Error(); err != nil {
-		log.Printf("Error syncing conversation: %v", err)
+		bot.logger.Error("Error syncing conversation", "conversation_id", conv.ID, "error", err)
 		return
 	}
 
-	// Get the first unread item
+	// Introduce ourselves once in group threads we've been added to
+	if conv.IsGroup {
+		bot.introduceToGroup(conv)
+	}
+
+	// Get the newest message from someone else, skipping thread events
+	// such as group joins. Items are ordered newest first.
 	var lastMessage *goinsta.InboxItem
-	for i := len(conv.Items) - 1; i >= 0; i-- {
-		item := conv.Items[i]
-		if item.UserID != bot.insta.Account.ID {
+	for _, item := range conv.Items {
+		if item.UserID != bot.insta.Account.ID && item.Type != "action_log" {
 			lastMessage = item
 			break
 		}
 	}
 
-	// if lastMessage != nil {
-	// Only respond if this user hasn't received an auto-reply before
+	// Vanish-mode messages disappear once the thread leaves vanish mode,
+	// so a held message is answered even though it's gone from the thread
+	if !conv.ShhModeEnabled {
+		if held := bot.heldForVanish.Take(conv.ID); held != nil && lastMessage == nil {
+			lastMessage = held
+		}
+	}
+
+	if lastMessage == nil || !bot.allowVanishReply(conv, lastMessage) {
+		return
+	}
+
+	// Users are pruned only after the retention window has passed since
+	// their reply, so older messages may have been answered already
+	if bot.beforeRetention(receivedAt(lastMessage)) {
+		return
+	}
+
+	// A user who wrote earlier in this thread has messaged before, even if
+	// it was before the bot started tracking first-seen times
 	userID := lastMessage.UserID
-	log.Println("user ID: ", userID)
-	log.Println("has responded to auto-reply: ", !bot.respondedUsers.HasResponded(userID))
-	if bot.respondedUsers.HasResponded(userID) {
-		log.Println("responding to user: ", userID)
-		bot.respondToMessage(conv, lastMessage)
+	bot.seenUsers.MarkSeen(userID, receivedAt(firstMessageFrom(conv, lastMessage)))
+	returning := bot.seenUsers.MarkSeen(userID, receivedAt(lastMessage))
+
+	// Only respond if this user hasn't received an auto-reply before.
+	// Returning users are therefore those who messaged before without
+	// getting one: before the bot was deployed, during working hours or
+	// before being pruned after the retention window.
+	if !bot.respondedUsers.HasResponded(userID) {
+		bot.logger.Debug("Responding to user", "conversation_id", conv.ID, "user_id", userID)
+		bot.respondToMessage(conv, lastMessage, returning)
 	}
-	// }
+}
 
+// firstMessageFrom returns the oldest item in conv from the sender of
+// message, or message itself if there is none
+func firstMessageFrom(conv *goinsta.Conversation, message *goinsta.InboxItem) *goinsta.InboxItem {
+	first := message
+	for _, item := range conv.Items {
+		if item.UserID == message.UserID && item.Type != "action_log" && receivedAt(item).Before(receivedAt(first)) {
+			first = item
+		}
+	}
+	return first
 }
 
-// respondToMessage sends an auto-reply based on message content
-func (bot *InstagramBot) respondToMessage(conv *goinsta.Conversation, item *goinsta.InboxItem) {
-	// Get message text
-	messageText := ""
-	if item.Text != "" {
-		messageText = strings.ToLower(item.Text)
+// respondToMessage sends an auto-reply based on message content. returning
+// reports whether the sender messaged the account before item.
+func (bot *InstagramBot) respondToMessage(conv *goinsta.Conversation, item *goinsta.InboxItem, returning bool) {
+	data := newResponseData(conv, item)
+
+	// During working hours the team replies itself
+	schedule := bot.config().Schedule
+	inHours := false
+	if schedule != nil {
+		var err error
+		if inHours, err = schedule.InHours(receivedAt(item)); err != nil {
+			bot.logger.Error("Error checking schedule", "error", err)
+		} else if inHours && schedule.InHoursResponse == "" {
+			bot.logger.Info("Message received during working hours, not auto-replying", "conversation_id", conv.ID, "user_id", item.UserID)
+			return
+		}
 	}
 
 	// Determine appropriate response
-	responseText := bot.determineResponse(messageText)
+	responseText, matched := bot.chooseResponse(item, returning)
+
+	// Hand unmatched messages over to a human, once even if the reply
+	// below has to be retried
+	if !matched && bot.config().UnmatchedForwardURL != "" && !bot.forwarded.Has(item.ID) {
+		err := bot.forwardUnmatched(UnmatchedMessage{
+			UserID:   item.UserID,
+			Username: data.Username,
+			Text:     itemText(item),
+			ThreadID: conv.ID,
+		})
+		if err != nil {
+			bot.logger.Error("Error forwarding unmatched message", "conversation_id", conv.ID, "user_id", item.UserID, "error", err)
+		} else if bot.config().SuppressDefaultOnForward {
+			bot.respondedUsers.MarkResponded(item.UserID)
+			bot.logger.Info("Forwarded message for human follow-up", "conversation_id", conv.ID, "user_id", item.UserID, "username", data.Username)
+			return
+		} else {
+			bot.forwarded.Add(item.ID)
+		}
+	}
 
-	log.Println("response: ", responseText)
+	if inHours {
+		responseText = schedule.InHoursResponse
+	}
+
+	// Fill in sender details
+	responseText = bot.renderResponse(responseText, data)
+
+	bot.logger.Debug("Determined response", "conversation_id", conv.ID, "user_id", item.UserID, "response", responseText, "matched", matched)
+
+	// The thread already shows this reply, which counts as answering
+	if bot.config().SuppressConsecutiveDuplicates && bot.isConsecutiveDuplicate(conv, responseText) {
+		bot.markResponded(item)
+		bot.logger.Info("Suppressing duplicate reply", "conversation_id", conv.ID, "user_id", item.UserID, "response", responseText)
+		return
+	}
+
+	// Journal the answer before acknowledging, so a restart sends the
+	// answer instead of leaving the user with only the acknowledgement
+	id := bot.journal(conv, item.UserID, responseText)
+	defer bot.finishQueued(id)
+
+	// Acknowledge first, once per message even if the answer is retried
+	if twoPhase := bot.config().TwoPhaseReply; twoPhase != nil && twoPhase.Enabled && !inHours && !bot.acked.Has(item.ID) {
+		if !bot.deliver(conv, item.UserID, bot.renderResponse(twoPhase.AckText, data), false) {
+			return
+		}
+		bot.acked.Add(item.ID)
+	}
 
 	// Send the response
-	if err := conv.Send(responseText); err != nil {
-		bot.logger.Printf("Error sending response: %v", err)
+	if !bot.deliver(conv, item.UserID, responseText, true) {
 		return
 	}
 
-	// Mark as responded
+	bot.markResponded(item)
+	bot.logger.Info("Sent auto-reply", "conversation_id", conv.ID, "user_id", item.UserID, "username", data.Username, "response", responseText)
+}
+
+// markResponded records that the sender of item has been answered
+func (bot *InstagramBot) markResponded(item *goinsta.InboxItem) {
+	bot.acked.Remove(item.ID)
+	bot.forwarded.Remove(item.ID)
 	bot.respondedUsers.MarkResponded(item.UserID)
-	bot.logger.Printf("Sent auto-reply to %s: %s", conv.Users[0].Username, responseText)
 }
 
-// determineResponse selects the appropriate response based on message content
-func (bot *InstagramBot) determineResponse(messageText string) string {
-	// Check for keyword matches
-	for pattern, response := range bot.config.ResponseRules {
-		if strings.Contains(messageText, pattern) {
-			return response
-		}
+// journal records text in the reply queue so it survives a restart,
+// returning the entry's ID for finishQueued
+func (bot *InstagramBot) journal(conv *goinsta.Conversation, userID int64, text string) string {
+	id, err := bot.replyQueue.Enqueue(QueuedReply{ThreadID: conv.ID, UserID: userID, Text: text})
+	if err != nil {
+		bot.logger.Error("Error saving reply queue", "error", err)
 	}
+	return id
+}
 
-	// Return default response if no match
-	return bot.config.DefaultResponse
+// deliver waits for a send slot within the rate limit and daily budget and
+// sends text. It reports whether the message was sent. Only sent,
+// counted messages use up the budget; acknowledgements don't.
+func (bot *InstagramBot) deliver(conv *goinsta.Conversation, userID int64, text string, counted bool) bool {
+	if !bot.throttle.Wait(text) {
+		bot.logger.Warn("Daily reply budget reached, not responding", "conversation_id", conv.ID, "user_id", userID)
+		return false
+	}
+
+	err := bot.send(conv, text)
+	bot.limiter.Observe(err)
+	if err != nil || !counted {
+		bot.throttle.Refund()
+	}
+	if err != nil {
+		bot.metrics.SendErrors.Add(1)
+		bot.logger.Error("Error sending response", "conversation_id", conv.ID, "user_id", userID, "error", err)
+		return false
+	}
+
+	bot.lastSent.Record(conv.ID, text)
+	bot.metrics.MessagesSent.Add(1)
+	if counted {
+		bot.metrics.RepliesSent.Add(1)
+	}
+	return true
 }
 
 // Cleanup performs cleanup operations
 func (bot *InstagramBot) Cleanup() {
 	// Export session for future use
 	if bot.insta != nil {
-		if err := bot.insta.Export(bot.config.ConfigPath); err != nil {
-			bot.logger.Printf("Failed to export session during cleanup: %v", err)
+		if err := bot.insta.Export(bot.config().ConfigPath); err != nil {
+			bot.logger.Error("Failed to export session during cleanup", "error", err)
 		}
 	}
 
 	// Save responded users
-	if err := bot.respondedUsers.Save(bot.config.RespondedUsersFile); err != nil {
-		bot.logger.Printf("Error saving responded users during cleanup: %v", err)
+	if err := bot.respondedUsers.Save(bot.config().RespondedUsersFile); err != nil {
+		bot.logger.Error("Error saving responded users during cleanup", "error", err)
 	}
 
-	bot.logger.Println("Bot cleanup completed")
-}
-
-func main() {
-	// Load configuration
-	configFile, err := os.ReadFile("config.json")
-	if err != nil {
-		log.Fatalf("Error reading config file: %v", err)
+	// Save joined group threads
+	if bot.config().JoinedThreadsFile != "" {
+		if err := bot.joinedThreads.Save(bot.config().JoinedThreadsFile); err != nil {
+			bot.logger.Error("Error saving joined threads during cleanup", "error", err)
+		}
 	}
 
-	var config Configuration
-	if err := json.Unmarshal(configFile, &config); err != nil {
-		log.Fatalf("Error parsing config file: %v", err)
+	// Save seen users
+	if bot.config().SeenUsersFile != "" {
+		if err := bot.seenUsers.Save(bot.config().SeenUsersFile); err != nil {
+			bot.logger.Error("Error saving seen users during cleanup", "error", err)
+		}
 	}
 
-	// Create and start the bot
-	bot, err := NewInstagramBot(&config)
+	bot.logger.Info("Bot cleanup completed")
+}
+
+func main() {
+	// Load configuration
+	configs, err := loadAccounts(configFile)
 	if err != nil {
-		log.Fatalf("Error initializing bot: %v", err)
+		log.Fatal(err)
 	}
 
-	fmt.Println("cfg: ", config)
-
-	// Set up cleanup on exit
-	defer bot.Cleanup()
+	// Run every account on its own, so one failing doesn't stop the others
+	var wg sync.WaitGroup
+	for _, config := range configs {
+		bot, err := NewInstagramBot(config)
+		if err != nil {
+			log.Printf("Error initializing bot for %s: %v", config.Username, err)
+			continue
+		}
 
-	// Login to Instagram
-	if err := bot.Login(); err != nil {
-		log.Fatalf("Error logging in: %v", err)
+		wg.Add(1)
+		go func(bot *InstagramBot) {
+			defer wg.Done()
+			bot.Run()
+		}(bot)
 	}
 
-	log.Println("Bot started")
-
-	// Start processing messages
-	bot.Start()
+	wg.Wait()
+	log.Fatal("No accounts are running")
 }
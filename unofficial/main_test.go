@@ -0,0 +1,401 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+// testAccountID is the ID of the account the test bots run as
+const testAccountID = 1
+
+// fakeSender records the messages a bot sends instead of sending them
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []string
+	// fail, if set, decides whether a send fails
+	fail func(text string) error
+}
+
+func (f *fakeSender) Send(conv *goinsta.Conversation, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail != nil {
+		if err := f.fail(text); err != nil {
+			return err
+		}
+	}
+	f.sent = append(f.sent, text)
+	return nil
+}
+
+// Texts returns the messages sent so far
+func (f *fakeSender) Texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+// newTestConfig returns a valid configuration keeping its files in a
+// temporary directory
+func newTestConfig(t *testing.T) *Configuration {
+	t.Helper()
+	dir := t.TempDir()
+	return &Configuration{
+		Config: responder.Config{
+			DefaultResponse: "Thanks for your message!",
+		},
+		Username:           "shop",
+		Password:           "secret",
+		ConfigPath:         filepath.Join(dir, "session.json"),
+		CheckInterval:      60,
+		LogFile:            filepath.Join(dir, "bot.log"),
+		LogLevel:           "error",
+		RespondedUsersFile: filepath.Join(dir, "responded_users.json"),
+	}
+}
+
+// newTestBot creates a bot for config that is logged in as testAccountID
+// and sends through the returned fake
+func newTestBot(t *testing.T, config *Configuration) (*InstagramBot, *fakeSender) {
+	t.Helper()
+	bot, err := NewInstagramBot(config)
+	if err != nil {
+		t.Fatalf("NewInstagramBot: %v", err)
+	}
+	bot.insta = &goinsta.Instagram{Account: &goinsta.Account{ID: testAccountID, Username: config.Username}}
+
+	sender := &fakeSender{}
+	bot.send = sender.Send
+	return bot, sender
+}
+
+// textItem returns a text message from userID received at the given time
+func textItem(id string, userID int64, text string, at time.Time) *goinsta.InboxItem {
+	return &goinsta.InboxItem{ID: id, UserID: userID, Type: "text", Text: text, Timestamp: at.UnixMicro()}
+}
+
+// newTestConversation returns a one-to-one thread with userID. Items are
+// given newest first, like goinsta orders them.
+func newTestConversation(id string, userID int64, items ...*goinsta.InboxItem) *goinsta.Conversation {
+	user := &goinsta.User{ID: userID, Username: "alice", FullName: "Alice"}
+	return &goinsta.Conversation{ID: id, Users: []*goinsta.User{user}, Inviter: user, Items: items}
+}
+
+func TestTwoPhaseReplySendsAckBeforeAnswer(t *testing.T) {
+	config := newTestConfig(t)
+	config.TwoPhaseReply = &TwoPhaseReply{Enabled: true, AckText: "Let me check that for you..."}
+	bot, sender := newTestBot(t, config)
+
+	item := textItem("i1", 42, "hello", time.Now())
+	bot.respondToMessage(newTestConversation("t1", 42, item), item, false)
+
+	want := []string{"Let me check that for you...", "Thanks for your message!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+	if !bot.respondedUsers.HasResponded(42) {
+		t.Error("user not marked as responded")
+	}
+}
+
+func TestTwoPhaseReplyDoesNotAckAgainWhenAnswerFails(t *testing.T) {
+	config := newTestConfig(t)
+	config.TwoPhaseReply = &TwoPhaseReply{Enabled: true, AckText: "One moment..."}
+	bot, sender := newTestBot(t, config)
+
+	failAnswer := true
+	sender.fail = func(text string) error {
+		if text == "Thanks for your message!" && failAnswer {
+			return errors.New("send failed")
+		}
+		return nil
+	}
+
+	item := textItem("i1", 42, "hello", time.Now())
+	conv := newTestConversation("t1", 42, item)
+	bot.respondToMessage(conv, item, false)
+	if bot.respondedUsers.HasResponded(42) {
+		t.Fatal("user marked as responded although the answer wasn't sent")
+	}
+
+	// The next check retries the answer only
+	failAnswer = false
+	bot.respondToMessage(conv, item, false)
+
+	want := []string{"One moment...", "Thanks for your message!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
+
+func TestTwoPhaseReplyJournalsOnlyTheAnswer(t *testing.T) {
+	config := newTestConfig(t)
+	config.PendingRepliesFile = filepath.Join(t.TempDir(), "pending.json")
+	config.TwoPhaseReply = &TwoPhaseReply{Enabled: true, AckText: "One moment..."}
+	bot, sender := newTestBot(t, config)
+
+	// Look at the journal while the acknowledgement is being sent
+	var journaled []QueuedReply
+	sender.fail = func(text string) error {
+		if text == "One moment..." {
+			restored, err := NewReplyQueue(config.PendingRepliesFile)
+			if err != nil {
+				t.Fatalf("NewReplyQueue: %v", err)
+			}
+			journaled = restored.Restored()
+		}
+		return nil
+	}
+
+	item := textItem("i1", 42, "hello", time.Now())
+	bot.respondToMessage(newTestConversation("t1", 42, item), item, false)
+
+	if len(journaled) != 1 || journaled[0].Text != "Thanks for your message!" {
+		t.Fatalf("journal during acknowledgement = %+v, want only the answer", journaled)
+	}
+}
+
+func TestTwoPhaseReplySkipsAckWhenForwardReplacesReply(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	config := newTestConfig(t)
+	config.TwoPhaseReply = &TwoPhaseReply{Enabled: true, AckText: "One moment..."}
+	config.UnmatchedForwardURL = server.URL
+	config.SuppressDefaultOnForward = true
+	bot, sender := newTestBot(t, config)
+
+	item := textItem("i1", 42, "something unusual", time.Now())
+	bot.respondToMessage(newTestConversation("t1", 42, item), item, false)
+
+	if got := sender.Texts(); len(got) != 0 {
+		t.Fatalf("sent %q, want nothing", got)
+	}
+}
+
+func TestPruneDropsOnlyExpiredUsers(t *testing.T) {
+	ru := &RespondedUsers{Users: map[int64]time.Time{
+		1: time.Now().Add(-48 * time.Hour),
+		2: time.Now().Add(-time.Hour),
+	}}
+
+	if removed := ru.Prune(24 * time.Hour); removed != 1 {
+		t.Errorf("Prune removed %d users, want 1", removed)
+	}
+	if ru.HasResponded(1) || !ru.HasResponded(2) {
+		t.Errorf("after Prune users = %v, want only user 2", ru.Users)
+	}
+}
+
+func TestPrunedUserNotAnsweredAgainForSameMessage(t *testing.T) {
+	config := newTestConfig(t)
+	config.RespondedUsersRetentionHours = 24
+	config.PruneEveryChecks = 1
+	bot, sender := newTestBot(t, config)
+
+	// Answered two days ago, then forgotten by the prune
+	message := textItem("i1", 42, "hello", time.Now().Add(-49*time.Hour))
+	bot.respondedUsers.Users[42] = time.Now().Add(-48 * time.Hour)
+	bot.metrics.CheckCycles.Add(1)
+	bot.pruneRespondedUsers()
+	if bot.respondedUsers.HasResponded(42) {
+		t.Fatal("user not pruned")
+	}
+
+	bot.processConversation(newTestConversation("t1", 42, message))
+	if got := sender.Texts(); len(got) != 0 {
+		t.Fatalf("sent %q for the message already answered", got)
+	}
+
+	// A new message is answered again
+	bot.processConversation(newTestConversation("t1", 42, textItem("i2", 42, "hello again", time.Now())))
+	if got := sender.Texts(); len(got) != 1 {
+		t.Fatalf("sent %q, want one reply to the new message", got)
+	}
+}
+
+func TestPanicInOneConversationSparesTheOthers(t *testing.T) {
+	config := newTestConfig(t)
+	config.ResponseRules = map[string]responder.Response{"boom": {Text: "BOOM"}}
+	bot, sender := newTestBot(t, config)
+	sender.fail = func(text string) error {
+		if text == "BOOM" {
+			panic("send exploded")
+		}
+		return nil
+	}
+
+	conversations := []*goinsta.Conversation{
+		newTestConversation("t1", 41, textItem("i1", 41, "boom", time.Now())),
+		newTestConversation("t2", 42, textItem("i2", 42, "hello", time.Now())),
+		// goinsta leaves Inviter unset for some threads
+		{ID: "t3", Items: []*goinsta.InboxItem{textItem("i3", 43, "hello", time.Now())}},
+	}
+	bot.processConversations(conversations)
+
+	if got := sender.Texts(); len(got) != 2 {
+		t.Errorf("sent %q, want replies in the two other threads", got)
+	}
+
+	// The panicking worker gave its slot back
+	bot.limiter.mu.Lock()
+	inFlight := bot.limiter.inFlight
+	bot.limiter.mu.Unlock()
+	if inFlight != 0 {
+		t.Errorf("%d limiter slots still taken", inFlight)
+	}
+}
+
+func TestAnsweredUserIsNotAnsweredAgain(t *testing.T) {
+	bot, sender := newTestBot(t, newTestConfig(t))
+
+	conv := newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now()))
+	bot.processConversation(conv)
+	bot.processConversation(newTestConversation("t1", 42, textItem("i2", 42, "are you there?", time.Now())))
+
+	if got := sender.Texts(); len(got) != 1 {
+		t.Errorf("sent %q, want a single auto-reply per user", got)
+	}
+}
+
+func TestReplyIsPersonalized(t *testing.T) {
+	config := newTestConfig(t)
+	config.DefaultResponse = "Hi {{.Username}}, thanks for reaching out!"
+	bot, sender := newTestBot(t, config)
+
+	bot.processConversation(newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now())))
+
+	want := []string{"Hi alice, thanks for reaching out!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestWorkerPoolAnswersEachConversationOnceWithinConcurrency(t *testing.T) {
+	config := newTestConfig(t)
+	config.Concurrency = 3
+	bot, _ := newTestBot(t, config)
+
+	var (
+		mu       sync.Mutex
+		replies  = make(map[string]int)
+		inFlight int
+		maxSeen  int
+	)
+	bot.send = func(conv *goinsta.Conversation, text string) error {
+		mu.Lock()
+		replies[conv.ID]++
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}
+
+	var conversations []*goinsta.Conversation
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("t%d", i)
+		userID := int64(100 + i)
+		conversations = append(conversations, newTestConversation(id, userID, textItem("i"+id, userID, "hello", time.Now())))
+	}
+	bot.processConversations(conversations)
+
+	for _, conv := range conversations {
+		if n := replies[conv.ID]; n != 1 {
+			t.Errorf("conversation %s answered %d times, want once", conv.ID, n)
+		}
+	}
+	if maxSeen > config.Concurrency {
+		t.Errorf("%d conversations handled at once, want at most %d", maxSeen, config.Concurrency)
+	}
+	if maxSeen < 2 {
+		t.Errorf("conversations handled one at a time, want them in parallel")
+	}
+}
+
+func TestSpanishMessageGetsSpanishTranslation(t *testing.T) {
+	config := newTestConfig(t)
+	config.ResponseRules = map[string]responder.Response{
+		"precio": {Text: "Our prices start at $10.", Translations: map[string]string{"es": "Nuestros precios empiezan en $10."}},
+	}
+	bot, sender := newTestBot(t, config)
+
+	bot.processConversation(newTestConversation("t1", 42, textItem("i1", 42, "Hola, ¿cuál es el precio?", time.Now())))
+
+	want := []string{"Nuestros precios empiezan en $10."}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestBotRepliesFromSharedRules(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, singleAccountConfig(dir, `{
+		"price": "Our prices start at $10.",
+		"hola": {"text": "Hello!", "translations": {"es": "¡Hola! ¿En qué podemos ayudarte?"}}
+	}`))
+	configs, err := loadAccounts(path)
+	if err != nil {
+		t.Fatalf("loadAccounts: %v", err)
+	}
+	bot, sender := newTestBot(t, configs[0])
+
+	for i, text := range []string{"What is the price?", "Hola, ¿tienen envío gratis?", "xqzv"} {
+		userID := int64(100 + i)
+		bot.processConversation(newTestConversation(fmt.Sprintf("t%d", i), userID, textItem(fmt.Sprintf("i%d", i), userID, text, time.Now())))
+	}
+
+	want := []string{"Our prices start at $10.", "¡Hola! ¿En qué podemos ayudarte?", "Thanks!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestInboxHandlesPendingAndRegularConversationsOnce(t *testing.T) {
+	bot, _ := newTestBot(t, newTestConfig(t))
+
+	var mu sync.Mutex
+	replies := make(map[string]int)
+	bot.send = func(conv *goinsta.Conversation, text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		replies[conv.ID]++
+		return nil
+	}
+
+	conversation := func(id string, userID int64) *goinsta.Conversation {
+		return newTestConversation(id, userID, textItem("i-"+id, userID, "hello", time.Now()))
+	}
+	approved := conversation("t2", 102)
+	pending := []*goinsta.Conversation{conversation("t1", 101), approved}
+	// A request approved since the pending sync shows up in both lists
+	regular := []*goinsta.Conversation{conversation("t3", 103), approved, conversation("t4", 104)}
+
+	bot.processInbox(regular, pending)
+
+	want := map[string]int{"t1": 1, "t2": 1, "t3": 1, "t4": 1}
+	if !reflect.DeepEqual(replies, want) {
+		t.Errorf("replies per conversation = %v, want %v", replies, want)
+	}
+	if got := bot.metrics.ConversationsProcessed.Load(); got != 4 {
+		t.Errorf("processed %d conversations, want each of the 4 once", got)
+	}
+}
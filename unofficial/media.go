@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/Davincible/goinsta"
+)
+
+// messageCategory groups inbox items by how the bot answers them
+type messageCategory int
+
+const (
+	categoryText messageCategory = iota
+	categoryMedia
+	categoryStoryReply
+	categoryOther
+)
+
+// categorizeMessage determines the category of an inbox item from its type
+func categorizeMessage(item *goinsta.InboxItem) messageCategory {
+	switch item.Type {
+	case "text", "link":
+		return categoryText
+	case "reel_share":
+		// Replies and reactions to our story, as opposed to stories shared
+		// with us or mentions of the account
+		if item.Reel != nil && (item.Reel.Type == "reply" || item.Reel.Type == "reaction") {
+			return categoryStoryReply
+		}
+		return categoryMedia
+	case "media", "raven_media", "visual_media", "voice_media", "animated_media",
+		"media_share", "clip", "felix_share", "story_share":
+		return categoryMedia
+	}
+
+	if item.Text != "" {
+		return categoryText
+	}
+	return categoryOther
+}
+
+// forwardedTypes are the item types Instagram creates when a post, reel,
+// story or profile is shared into a chat. goinsta doesn't expose a
+// forwarded flag, so every share counts as forwarded, whether it was
+// passed on from another chat or shared directly.
+var forwardedTypes = map[string]bool{
+	"media_share": true,
+	"story_share": true,
+	"felix_share": true,
+	"clip":        true,
+	"profile":     true,
+}
+
+// isForwarded reports whether item is a share that may have been
+// forwarded from elsewhere
+func isForwarded(item *goinsta.InboxItem) bool {
+	return forwardedTypes[item.Type]
+}
+
+// itemText returns the text the user wrote with an inbox item, if any
+func itemText(item *goinsta.InboxItem) string {
+	switch {
+	case item.Text != "":
+		return item.Text
+	case item.Reel != nil && item.Reel.Text != "":
+		return item.Reel.Text
+	default:
+		return item.Link.Text
+	}
+}
+
+// chooseResponse picks the response for an inbox item, reporting whether it
+// was matched by a response rule or a forwarded, media or story reply
+// response. Keyword rules are only consulted when the item carries text.
+func (bot *InstagramBot) chooseResponse(item *goinsta.InboxItem, returning bool) (string, bool) {
+	if bot.config().ForwardedMessageResponse != "" && isForwarded(item) {
+		return bot.config().ForwardedMessageResponse, true
+	}
+
+	switch categorizeMessage(item) {
+	case categoryStoryReply:
+		if bot.config().StoryReplyResponse != "" {
+			return bot.config().StoryReplyResponse, true
+		}
+	case categoryMedia:
+		if bot.config().MediaResponse != "" {
+			return bot.config().MediaResponse, true
+		}
+	}
+
+	text := itemText(item)
+	if text == "" {
+		return bot.engine.Load().Default(), false
+	}
+	return bot.engine.Load().Match(text, returning)
+}
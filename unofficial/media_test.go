@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+// reelShareItem returns a reel_share item of reelType, such as a "reply"
+// to our story, carrying text. goinsta's reel share type is unexported, so
+// the item is decoded like one from the API.
+func reelShareItem(id string, userID int64, reelType, text string) *goinsta.InboxItem {
+	data, err := json.Marshal(map[string]interface{}{
+		"item_id":    id,
+		"user_id":    userID,
+		"item_type":  "reel_share",
+		"reel_share": map[string]string{"type": reelType, "text": text},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var item goinsta.InboxItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		panic(err)
+	}
+	return &item
+}
+
+func TestCategorizeMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		item *goinsta.InboxItem
+		want messageCategory
+	}{
+		{"text", &goinsta.InboxItem{Type: "text", Text: "hi"}, categoryText},
+		{"link", &goinsta.InboxItem{Type: "link"}, categoryText},
+		{"photo", &goinsta.InboxItem{Type: "media"}, categoryMedia},
+		{"disappearing photo", &goinsta.InboxItem{Type: "raven_media"}, categoryMedia},
+		{"voice note", &goinsta.InboxItem{Type: "voice_media"}, categoryMedia},
+		{"shared post", &goinsta.InboxItem{Type: "media_share"}, categoryMedia},
+		{"story reply", reelShareItem("i1", 42, "reply", "love it"), categoryStoryReply},
+		{"story reaction", reelShareItem("i1", 42, "reaction", "🔥"), categoryStoryReply},
+		{"story mention", reelShareItem("i1", 42, "mention", ""), categoryMedia},
+		{"unknown type with text", &goinsta.InboxItem{Type: "new_thing", Text: "hi"}, categoryText},
+		{"unknown type", &goinsta.InboxItem{Type: "like"}, categoryOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := categorizeMessage(tt.item); got != tt.want {
+				t.Errorf("categorizeMessage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepliesByCategory(t *testing.T) {
+	config := newTestConfig(t)
+	config.MediaResponse = "Nice photo!"
+	config.StoryReplyResponse = "Thanks for replying to our story!"
+
+	tests := []struct {
+		name string
+		item *goinsta.InboxItem
+		want string
+	}{
+		{"text", textItem("i1", 42, "hello", time.Now()), "Thanks for your message!"},
+		{"media", &goinsta.InboxItem{ID: "i1", UserID: 42, Type: "media", Timestamp: time.Now().UnixMicro()}, "Nice photo!"},
+		{"story reply", reelShareItem("i1", 42, "reply", "love it"), "Thanks for replying to our story!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, sender := newTestBot(t, config)
+			bot.respondToMessage(newTestConversation("t1", 42, tt.item), tt.item, false)
+
+			if got := sender.Texts(); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoryReplyTextMatchesRulesWithoutStoryResponse(t *testing.T) {
+	config := newTestConfig(t)
+	config.ResponseRules = map[string]responder.Response{"price": {Text: "From $10."}}
+	bot, _ := newTestBot(t, config)
+
+	if got, matched := bot.chooseResponse(reelShareItem("i1", 42, "reply", "what's the price?"), false); got != "From $10." || !matched {
+		t.Errorf("chooseResponse = %q, %v; want the price rule", got, matched)
+	}
+}
+
+func TestChooseResponseUsesLocalizedDefaultWithoutText(t *testing.T) {
+	config := newTestConfig(t)
+	config.DefaultResponse = ""
+	config.DefaultResponses = map[string]string{"en": "Thanks for the photo!"}
+	bot, _ := newTestBot(t, config)
+
+	item := &goinsta.InboxItem{ID: "i1", UserID: 42, Type: "media"}
+	if got, _ := bot.chooseResponse(item, false); got != "Thanks for the photo!" {
+		t.Errorf("chooseResponse = %q, want the localized default", got)
+	}
+}
+
+func TestForwardedResponseReplacesMediaResponseForShares(t *testing.T) {
+	config := newTestConfig(t)
+	config.MediaResponse = "Nice photo!"
+	config.ForwardedMessageResponse = "Thanks for sharing!"
+	bot, _ := newTestBot(t, config)
+
+	tests := []struct {
+		itemType string
+		want     string
+	}{
+		{"media_share", "Thanks for sharing!"},
+		{"clip", "Thanks for sharing!"},
+		{"story_share", "Thanks for sharing!"},
+		{"profile", "Thanks for sharing!"},
+		{"media", "Nice photo!"},
+		{"voice_media", "Nice photo!"},
+	}
+	for _, tt := range tests {
+		item := &goinsta.InboxItem{ID: "i1", UserID: 42, Type: tt.itemType}
+		got, matched := bot.chooseResponse(item, false)
+		if got != tt.want || !matched {
+			t.Errorf("%s item got %q, %v; want %q, true", tt.itemType, got, matched, tt.want)
+		}
+	}
+}
+
+func TestSharesUseMediaResponseWithoutForwardedResponse(t *testing.T) {
+	config := newTestConfig(t)
+	config.MediaResponse = "Nice post!"
+	bot, _ := newTestBot(t, config)
+
+	item := &goinsta.InboxItem{ID: "i1", UserID: 42, Type: "media_share"}
+	if got, _ := bot.chooseResponse(item, false); got != "Nice post!" {
+		t.Errorf("chooseResponse = %q, want the media response", got)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minHealthWindow is the shortest time a sync is considered recent
+const minHealthWindow = time.Minute
+
+// Metrics holds counters describing the bot's activity
+type Metrics struct {
+	// RepliesSent counts auto-replies, MessagesSent every message sent
+	// including acknowledgements and group introductions
+	RepliesSent            atomic.Int64
+	MessagesSent           atomic.Int64
+	SendErrors             atomic.Int64
+	SyncErrors             atomic.Int64
+	CheckCycles            atomic.Int64
+	ConversationsProcessed atomic.Int64
+	// LastSync is the unix time of the last successful inbox sync
+	LastSync atomic.Int64
+	LoggedIn atomic.Bool
+}
+
+// Snapshot returns the current counter values keyed by metric name
+func (m *Metrics) Snapshot() map[string]int64 {
+	return map[string]int64{
+		"replies_sent":            m.RepliesSent.Load(),
+		"messages_sent":           m.MessagesSent.Load(),
+		"send_errors":             m.SendErrors.Load(),
+		"sync_errors":             m.SyncErrors.Load(),
+		"check_cycles":            m.CheckCycles.Load(),
+		"conversations_processed": m.ConversationsProcessed.Load(),
+		"last_sync_unixtime":      m.LastSync.Load(),
+	}
+}
+
+// registeredMetrics maps accounts to their metrics for expvar. Accounts are
+// keyed by config_path, which unlike the username is always set and
+// unique per account.
+var registeredMetrics sync.Map
+
+// publishOnce publishes the expvar variable on first registration
+var publishOnce sync.Once
+
+// registerMetrics exposes an account's metrics under the "instagram_bot"
+// expvar, served at /debug/vars, keyed by account
+func registerMetrics(account string, m *Metrics) {
+	registeredMetrics.Store(account, m)
+	publishOnce.Do(func() {
+		expvar.Publish("instagram_bot", expvar.Func(func() interface{} {
+			accounts := make(map[string]map[string]int64)
+			registeredMetrics.Range(func(key, value interface{}) bool {
+				accounts[key.(string)] = value.(*Metrics).Snapshot()
+				return true
+			})
+			return accounts
+		}))
+	})
+}
+
+// Healthy reports whether the bot is logged in and synced within window
+func (m *Metrics) Healthy(window time.Duration) bool {
+	lastSync := m.LastSync.Load()
+	if !m.LoggedIn.Load() || lastSync == 0 {
+		return false
+	}
+	return time.Since(time.Unix(lastSync, 0)) <= window
+}
+
+// healthWindow allows a few missed check intervals before reporting
+// unhealthy, using the interval checks are actually running at
+func (bot *InstagramBot) healthWindow() time.Duration {
+	interval := bot.checkInterval.Load()
+	if interval == 0 {
+		interval = int64(bot.config().CheckInterval)
+	}
+	window := 3 * time.Duration(interval) * time.Second
+	if window < minHealthWindow {
+		window = minHealthWindow
+	}
+	return window
+}
+
+// metricsHandler serves /healthz, /metrics and /debug/vars
+func (bot *InstagramBot) metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		healthy := bot.metrics.Healthy(bot.healthWindow())
+
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"healthy":            healthy,
+			"logged_in":          bot.metrics.LoggedIn.Load(),
+			"last_sync_unixtime": bot.metrics.LastSync.Load(),
+		})
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetric(w, "instagram_bot_replies_sent_total", "counter", "Auto-replies sent.", bot.metrics.RepliesSent.Load())
+		writeMetric(w, "instagram_bot_messages_sent_total", "counter", "Messages sent, including acknowledgements and group introductions.", bot.metrics.MessagesSent.Load())
+		writeMetric(w, "instagram_bot_send_errors_total", "counter", "Failed sends.", bot.metrics.SendErrors.Load())
+		writeMetric(w, "instagram_bot_sync_errors_total", "counter", "Failed inbox syncs.", bot.metrics.SyncErrors.Load())
+		writeMetric(w, "instagram_bot_check_cycles_total", "counter", "Inbox check cycles run.", bot.metrics.CheckCycles.Load())
+		writeMetric(w, "instagram_bot_conversations_processed_total", "counter", "Conversations processed.", bot.metrics.ConversationsProcessed.Load())
+		writeMetric(w, "instagram_bot_last_sync_unixtime", "gauge", "Unix time of the last successful inbox sync.", bot.metrics.LastSync.Load())
+		writeMetric(w, "instagram_bot_concurrency_limit", "gauge", "Conversations currently allowed in parallel.", int64(bot.limiter.Limit()))
+	})
+
+	return mux
+}
+
+// writeMetric writes a single metric in the Prometheus text format
+func writeMetric(w http.ResponseWriter, name, kind, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
+
+// StartMetricsServer serves the metrics endpoints in the background when a
+// metrics port is configured
+func (bot *InstagramBot) StartMetricsServer() {
+	if bot.config().MetricsPort == 0 {
+		return
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", bot.config().MetricsPort),
+		Handler:           bot.metricsHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		bot.logger.Info("Metrics server listening", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil {
+			bot.logger.Error("Metrics server stopped", "error", err)
+		}
+	}()
+}
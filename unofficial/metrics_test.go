@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+// getDebugVars scrapes /debug/vars and returns the bot's accounts
+func getDebugVars(t *testing.T, bot *InstagramBot) map[string]map[string]int64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	bot.metricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+
+	var vars struct {
+		Accounts map[string]map[string]int64 `json:"instagram_bot"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("decoding /debug/vars: %v", err)
+	}
+	return vars.Accounts
+}
+
+func TestDebugVarsKeepsAccountsWithoutUsernameApart(t *testing.T) {
+	configA, configB := newTestConfig(t), newTestConfig(t)
+	configA.Username, configB.Username = "", ""
+	a, _ := newTestBot(t, configA)
+	b, _ := newTestBot(t, configB)
+
+	a.metrics.RepliesSent.Add(3)
+	b.metrics.RepliesSent.Add(5)
+	b.metrics.CheckCycles.Add(2)
+
+	accounts := getDebugVars(t, a)
+	if got := accounts[configA.ConfigPath]["replies_sent"]; got != 3 {
+		t.Errorf("account a replies_sent = %d, want 3", got)
+	}
+	if got := accounts[configB.ConfigPath]["replies_sent"]; got != 5 {
+		t.Errorf("account b replies_sent = %d, want 5", got)
+	}
+	if got := accounts[configB.ConfigPath]["check_cycles"]; got != 2 {
+		t.Errorf("account b check_cycles = %d, want 2", got)
+	}
+}
+
+// get requests path from the bot's metrics server
+func get(t *testing.T, bot *InstagramBot, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	bot.metricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec
+}
+
+func TestHealthzReflectsLoginAndSync(t *testing.T) {
+	bot, _ := newTestBot(t, newTestConfig(t))
+
+	health := func() (int, map[string]interface{}) {
+		rec := get(t, bot, "/healthz")
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding /healthz: %v", err)
+		}
+		return rec.Code, body
+	}
+
+	if code, body := health(); code != http.StatusServiceUnavailable || body["healthy"] != false {
+		t.Errorf("before login got %d %v, want 503 unhealthy", code, body)
+	}
+
+	// A successful login and inbox sync
+	bot.metrics.LoggedIn.Store(true)
+	bot.metrics.LastSync.Store(time.Now().Unix())
+	if code, body := health(); code != http.StatusOK || body["healthy"] != true || body["logged_in"] != true {
+		t.Errorf("after sync got %d %v, want 200 healthy", code, body)
+	}
+
+	// No sync for longer than three check intervals
+	bot.metrics.LastSync.Store(time.Now().Add(-10 * time.Minute).Unix())
+	if code, body := health(); code != http.StatusServiceUnavailable || body["healthy"] != false {
+		t.Errorf("with a stale sync got %d %v, want 503 unhealthy", code, body)
+	}
+}
+
+func TestMetricsCountsActivity(t *testing.T) {
+	config := newTestConfig(t)
+	config.ResponseRules = map[string]responder.Response{"broken": {Text: "this will fail"}}
+	bot, sender := newTestBot(t, config)
+	sender.fail = func(text string) error {
+		if strings.Contains(text, "fail") {
+			return errors.New("send failed")
+		}
+		return nil
+	}
+
+	bot.processConversations([]*goinsta.Conversation{
+		newTestConversation("t1", 41, textItem("i1", 41, "hello", time.Now())),
+		newTestConversation("t2", 42, textItem("i2", 42, "hi there", time.Now())),
+		newTestConversation("t3", 43, textItem("i3", 43, "broken", time.Now())),
+	})
+	bot.metrics.CheckCycles.Add(1)
+
+	rec := get(t, bot, "/metrics")
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	for _, line := range []string{
+		"instagram_bot_replies_sent_total 2",
+		"instagram_bot_messages_sent_total 2",
+		"instagram_bot_send_errors_total 1",
+		"instagram_bot_conversations_processed_total 3",
+		"instagram_bot_check_cycles_total 1",
+		"# TYPE instagram_bot_replies_sent_total counter",
+		"# TYPE instagram_bot_concurrency_limit gauge",
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("/metrics is missing %q:\n%s", line, rec.Body)
+		}
+	}
+}
+
+func TestRepliesSentCountsOnlyAutoReplies(t *testing.T) {
+	config := newTestConfig(t)
+	config.TwoPhaseReply = &TwoPhaseReply{Enabled: true, AckText: "One moment..."}
+	config.GroupJoinResponse = "Hi everyone!"
+	config.JoinedThreadsFile = filepath.Join(t.TempDir(), "joined.json")
+	bot, sender := newTestBot(t, config)
+
+	bot.processConversation(newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now())))
+	group := &goinsta.Conversation{ID: "g1", IsGroup: true, Items: []*goinsta.InboxItem{actionLogItem("a1", "alice added you to the group.")}}
+	bot.processConversation(group)
+
+	if got := len(sender.Texts()); got != 3 {
+		t.Fatalf("sent %q, want acknowledgement, answer and introduction", sender.Texts())
+	}
+	if got := bot.metrics.RepliesSent.Load(); got != 1 {
+		t.Errorf("replies_sent = %d, want the one auto-reply", got)
+	}
+	if got := bot.metrics.MessagesSent.Load(); got != 3 {
+		t.Errorf("messages_sent = %d, want 3", got)
+	}
+	if accounts := getDebugVars(t, bot); accounts[config.ConfigPath]["replies_sent"] != 1 || accounts[config.ConfigPath]["messages_sent"] != 3 {
+		t.Errorf("/debug/vars = %v", accounts[config.ConfigPath])
+	}
+}
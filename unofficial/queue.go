@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// maxQueuedReplyAge is how long a restored reply waits for its thread to
+// show up in the inbox before it is dropped
+const maxQueuedReplyAge = 24 * time.Hour
+
+// QueuedReply is a reply that has been decided on but not yet sent
+type QueuedReply struct {
+	ID       string    `json:"id"`
+	ThreadID string    `json:"thread_id"`
+	UserID   int64     `json:"user_id"`
+	Text     string    `json:"text"`
+	QueuedAt time.Time `json:"queued_at"`
+
+	// Restored marks replies loaded from disk at startup
+	Restored bool `json:"-"`
+}
+
+// ReplyQueue journals replies waiting to be sent, so replies held up by
+// the send throttle survive a crash or restart
+type ReplyQueue struct {
+	path  string
+	mu    sync.Mutex
+	seq   int64
+	items []QueuedReply
+}
+
+// NewReplyQueue loads replies left over from a previous run. An empty path
+// keeps the queue in memory only.
+func NewReplyQueue(filepath string) (*ReplyQueue, error) {
+	q := &ReplyQueue{path: filepath}
+
+	// Load previously queued replies if file exists
+	if _, err := os.Stat(filepath); err == nil {
+		data, err := os.ReadFile(filepath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading reply queue file: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &q.items); err != nil {
+			return nil, fmt.Errorf("error unmarshaling reply queue: %w", err)
+		}
+		for i := range q.items {
+			q.items[i].Restored = true
+		}
+	}
+
+	return q, nil
+}
+
+// Enqueue journals a reply and returns its ID
+func (q *ReplyQueue) Enqueue(reply QueuedReply) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	reply.ID = strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(q.seq, 36)
+	reply.QueuedAt = time.Now()
+	q.items = append(q.items, reply)
+
+	return reply.ID, q.save()
+}
+
+// Done removes a reply once it has been handled
+func (q *ReplyQueue) Done(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, item := range q.items {
+		if item.ID == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return q.save()
+		}
+	}
+	return nil
+}
+
+// Restored returns the replies left over from a previous run
+func (q *ReplyQueue) Restored() []QueuedReply {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var restored []QueuedReply
+	for _, item := range q.items {
+		if item.Restored {
+			restored = append(restored, item)
+		}
+	}
+	return restored
+}
+
+// save persists the queue to file, the caller must hold the lock
+func (q *ReplyQueue) save() error {
+	if q.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(q.items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling reply queue: %w", err)
+	}
+
+	if err := os.WriteFile(q.path, data, 0644); err != nil {
+		return fmt.Errorf("error writing reply queue file: %w", err)
+	}
+
+	return nil
+}
+
+// finishQueued removes a handled reply from the queue
+func (bot *InstagramBot) finishQueued(id string) {
+	if err := bot.replyQueue.Done(id); err != nil {
+		bot.logger.Error("Error saving reply queue", "error", err)
+	}
+}
+
+// dispatchRestoredReplies sends replies that were queued but not sent
+// before the last shutdown. It runs before the inbox is processed so the
+// users they were meant for aren't answered twice.
+func (bot *InstagramBot) dispatchRestoredReplies(conversations ...[]*goinsta.Conversation) {
+	threads := make(map[string]*goinsta.Conversation)
+	for _, list := range conversations {
+		for _, conv := range list {
+			threads[conv.ID] = conv
+		}
+	}
+
+	for _, reply := range bot.replyQueue.Restored() {
+		conv, ok := threads[reply.ThreadID]
+		if !ok {
+			// The thread may show up in a later sync
+			if time.Since(reply.QueuedAt) > maxQueuedReplyAge {
+				bot.logger.Warn("Dropping queued reply, thread not found", "conversation_id", reply.ThreadID, "user_id", reply.UserID)
+				bot.finishQueued(reply.ID)
+			}
+			continue
+		}
+
+		if bot.respondedUsers.HasResponded(reply.UserID) {
+			bot.finishQueued(reply.ID)
+			continue
+		}
+
+		// Keep the remaining replies for tomorrow's budget
+		if !bot.throttle.Wait(reply.Text) {
+			bot.logger.Warn("Daily reply budget reached, keeping queued replies")
+			return
+		}
+
+		bot.logger.Debug("Sending queued reply", "conversation_id", reply.ThreadID, "user_id", reply.UserID)
+
+		err := bot.send(conv, reply.Text)
+		bot.limiter.Observe(err)
+		bot.finishQueued(reply.ID)
+		if err != nil {
+			// The regular inbox pass will answer the message again
+			bot.throttle.Refund()
+			bot.metrics.SendErrors.Add(1)
+			bot.logger.Error("Error sending queued reply", "conversation_id", reply.ThreadID, "user_id", reply.UserID, "error", err)
+			continue
+		}
+
+		bot.lastSent.Record(conv.ID, reply.Text)
+		bot.metrics.MessagesSent.Add(1)
+		bot.metrics.RepliesSent.Add(1)
+		bot.respondedUsers.MarkResponded(reply.UserID)
+		bot.logger.Info("Sent queued auto-reply", "conversation_id", reply.ThreadID, "user_id", reply.UserID, "response", reply.Text)
+	}
+}
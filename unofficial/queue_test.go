@@ -0,0 +1,111 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// restartWithQueue journals reply as if the previous run crashed before
+// sending it, then starts a new bot on the same files
+func restartWithQueue(t *testing.T, reply QueuedReply) (*InstagramBot, *fakeSender) {
+	t.Helper()
+	config := newTestConfig(t)
+	config.PendingRepliesFile = filepath.Join(t.TempDir(), "pending.json")
+
+	crashed, err := NewReplyQueue(config.PendingRepliesFile)
+	if err != nil {
+		t.Fatalf("NewReplyQueue: %v", err)
+	}
+	if _, err := crashed.Enqueue(reply); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	return newTestBot(t, config)
+}
+
+// remaining returns the replies left in the queue file
+func remaining(t *testing.T, bot *InstagramBot) []QueuedReply {
+	t.Helper()
+	q, err := NewReplyQueue(bot.config().PendingRepliesFile)
+	if err != nil {
+		t.Fatalf("NewReplyQueue: %v", err)
+	}
+	return q.Restored()
+}
+
+func TestQueueRoundTripsThroughDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+	q, err := NewReplyQueue(path)
+	if err != nil {
+		t.Fatalf("NewReplyQueue: %v", err)
+	}
+	first, _ := q.Enqueue(QueuedReply{ThreadID: "t1", UserID: 41, Text: "one"})
+	if _, err := q.Enqueue(QueuedReply{ThreadID: "t2", UserID: 42, Text: "two"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if len(q.Restored()) != 0 {
+		t.Error("replies queued in this run reported as restored")
+	}
+	if err := q.Done(first); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+
+	reloaded, err := NewReplyQueue(path)
+	if err != nil {
+		t.Fatalf("NewReplyQueue: %v", err)
+	}
+	restored := reloaded.Restored()
+	if len(restored) != 1 || restored[0].Text != "two" || restored[0].ThreadID != "t2" || restored[0].UserID != 42 {
+		t.Fatalf("restored %+v, want only the unfinished reply", restored)
+	}
+}
+
+func TestRestoredReplyIsDispatchedOnce(t *testing.T) {
+	bot, sender := restartWithQueue(t, QueuedReply{ThreadID: "t1", UserID: 42, Text: "Thanks for your message!"})
+
+	conv := newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now()))
+	bot.dispatchRestoredReplies([]*goinsta.Conversation{conv})
+	bot.processConversation(conv)
+
+	want := []string{"Thanks for your message!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if !bot.respondedUsers.HasResponded(42) {
+		t.Error("user not marked as responded")
+	}
+	if left := remaining(t, bot); len(left) != 0 {
+		t.Errorf("queue still holds %+v", left)
+	}
+}
+
+func TestRestoredReplyWaitsForItsThread(t *testing.T) {
+	bot, sender := restartWithQueue(t, QueuedReply{ThreadID: "t1", UserID: 42, Text: "Thanks for your message!"})
+
+	bot.dispatchRestoredReplies([]*goinsta.Conversation{newTestConversation("t2", 43)})
+
+	if got := sender.Texts(); len(got) != 0 {
+		t.Errorf("sent %q before the thread showed up", got)
+	}
+	if left := remaining(t, bot); len(left) != 1 {
+		t.Errorf("queue holds %+v, want the reply kept", left)
+	}
+}
+
+func TestRestoredReplyIsDroppedForAnsweredUser(t *testing.T) {
+	bot, sender := restartWithQueue(t, QueuedReply{ThreadID: "t1", UserID: 42, Text: "Thanks for your message!"})
+	bot.respondedUsers.MarkResponded(42)
+
+	bot.dispatchRestoredReplies([]*goinsta.Conversation{newTestConversation("t1", 42)})
+
+	if got := sender.Texts(); len(got) != 0 {
+		t.Errorf("sent %q to a user already answered", got)
+	}
+	if left := remaining(t, bot); len(left) != 0 {
+		t.Errorf("queue still holds %+v", left)
+	}
+}
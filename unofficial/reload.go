@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// configFile is where the configuration is loaded from
+	configFile = "config.json"
+	// configPollInterval is how often the config file is checked for changes
+	configPollInterval = 5 * time.Second
+)
+
+// loadAccounts reads the config file and returns one validated
+// configuration per account, with safe mode applied. A file without an
+// "accounts" list describes a single account.
+func loadAccounts(path string) ([]*Configuration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var base Configuration
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	configs := []*Configuration{&base}
+	if len(base.Accounts) > 0 {
+		configs = make([]*Configuration, 0, len(base.Accounts))
+		for i, account := range base.Accounts {
+			config, err := accountConfiguration(data, account)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing account %d: %w", i+1, err)
+			}
+			configs = append(configs, config)
+		}
+	}
+
+	if err := validateAccounts(configs); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+
+	for _, config := range configs {
+		config.ApplySafeMode()
+	}
+	return configs, nil
+}
+
+// accountConfiguration lays an account entry over a fresh copy of the
+// shared settings in data
+func accountConfiguration(data []byte, account json.RawMessage) (*Configuration, error) {
+	var config Configuration
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(account, &keys); err != nil {
+		return nil, err
+	}
+	if _, ok := keys["accounts"]; ok {
+		return nil, errors.New("accounts cannot be nested")
+	}
+
+	// Decoding into a map adds to it, so clear maps the account replaces
+	if _, ok := keys["response_rules"]; ok {
+		config.ResponseRules = nil
+	}
+	if _, ok := keys["responses_by_lang"]; ok {
+		config.ResponsesByLang = nil
+	}
+	if _, ok := keys["default_responses"]; ok {
+		config.DefaultResponses = nil
+	}
+	if err := json.Unmarshal(account, &config); err != nil {
+		return nil, err
+	}
+
+	config.Accounts = nil
+	return &config, nil
+}
+
+// ReloadConfig swaps in this account's configuration from path if it's
+// valid. Accounts are matched by config_path. The session and the
+// responded users store are kept as they are; a new check interval is
+// picked up by Start after the current wait.
+func (bot *InstagramBot) ReloadConfig(path string) error {
+	configs, err := loadAccounts(path)
+	if err != nil {
+		return err
+	}
+
+	current := bot.config()
+	var config *Configuration
+	for _, c := range configs {
+		if c.ConfigPath == current.ConfigPath {
+			config = c
+			break
+		}
+	}
+	if config == nil {
+		return fmt.Errorf("no account with config_path %q in config file", current.ConfigPath)
+	}
+
+	// These are only read at startup
+	if config.Username != current.Username || config.LogFile != current.LogFile ||
+		!strings.EqualFold(config.LogFormat, current.LogFormat) || config.MetricsPort != current.MetricsPort ||
+		config.TwoFactorCodeSource != current.TwoFactorCodeSource || config.TwoFactorCodeFile != current.TwoFactorCodeFile ||
+		config.TwoFactorCodeTimeout != current.TwoFactorCodeTimeout {
+		bot.logger.Warn("Changes to username, log_file, log_format, metrics_port and the two_factor_code settings take effect after a restart")
+	}
+
+	if level, err := parseLogLevel(config.LogLevel); err == nil {
+		bot.logLevel.Set(level)
+	}
+
+	bot.throttle.Update(config)
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	bot.limiter.SetMax(concurrency)
+
+	bot.cfg.Store(config)
+	bot.engine.Store(bot.newEngine(config))
+	return nil
+}
+
+// WatchConfig polls the config file and reloads it whenever it changes. An
+// invalid config is logged and the current one keeps running.
+func (bot *InstagramBot) WatchConfig(path string) {
+	defer bot.recoverPanic("config watch")
+
+	lastMod := time.Time{}
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil {
+			bot.logger.Error("Error checking config file", "error", err)
+			continue
+		}
+		if info.ModTime().Equal(lastMod) {
+			continue
+		}
+		lastMod = info.ModTime()
+
+		if err := bot.ReloadConfig(path); err != nil {
+			bot.logger.Error("Rejected config reload, keeping current configuration", "error", err)
+			continue
+		}
+		bot.logger.Info("Configuration reloaded")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file into dir and returns its path
+func writeConfigFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	return path
+}
+
+func TestAccountReplacesLocalizedResponses(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, `{
+		"check_interval_seconds": 60,
+		"log_file": "`+filepath.Join(dir, "bot.log")+`",
+		"default_response": "Thanks!",
+		"responses_by_lang": {"en": {"price": "From $10."}, "es": {"precio": "Desde $10."}},
+		"default_responses": {"es": "¡Gracias!"},
+		"accounts": [
+			{"username": "a", "password": "x", "config_path": "`+filepath.Join(dir, "a.json")+`", "responded_users_file": "`+filepath.Join(dir, "a-responded.json")+`"},
+			{"username": "b", "password": "x", "config_path": "`+filepath.Join(dir, "b.json")+`", "responded_users_file": "`+filepath.Join(dir, "b-responded.json")+`",
+			 "responses_by_lang": {"es": {"hola": "¡Hola!"}}, "default_responses": {}}
+		]
+	}`)
+
+	configs, err := loadAccounts(path)
+	if err != nil {
+		t.Fatalf("loadAccounts: %v", err)
+	}
+
+	if got := len(configs[0].ResponsesByLang); got != 2 {
+		t.Errorf("account a has %d localized sets, want the 2 shared ones", got)
+	}
+	b := configs[1]
+	if len(b.ResponsesByLang) != 1 || len(b.ResponsesByLang["es"]) != 1 || b.ResponsesByLang["es"]["hola"] != "¡Hola!" {
+		t.Errorf("account b localized sets = %v, want only its own", b.ResponsesByLang)
+	}
+	if len(b.DefaultResponses) != 0 {
+		t.Errorf("account b localized defaults = %v, want none", b.DefaultResponses)
+	}
+}
+
+// twoAccountConfig describes two accounts sharing the default response
+func twoAccountConfig(dir string) string {
+	return `{
+		"check_interval_seconds": 60,
+		"log_file": "` + filepath.Join(dir, "bot.log") + `",
+		"log_level": "error",
+		"default_response": "Thanks!",
+		"response_rules": {"price": "From $10."},
+		"accounts": [
+			{"username": "shop_a", "password": "x", "config_path": "` + filepath.Join(dir, "a.json") + `",
+			 "responded_users_file": "` + filepath.Join(dir, "a-responded.json") + `"},
+			{"username": "shop_b", "password": "x", "config_path": "` + filepath.Join(dir, "b.json") + `",
+			 "responded_users_file": "` + filepath.Join(dir, "b-responded.json") + `",
+			 "response_rules": {"hours": "We open at 9."}}
+		]
+	}`
+}
+
+func TestAccountsGetIndependentBots(t *testing.T) {
+	dir := t.TempDir()
+	configs, err := loadAccounts(writeConfigFile(t, dir, twoAccountConfig(dir)))
+	if err != nil {
+		t.Fatalf("loadAccounts: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("loaded %d accounts, want 2", len(configs))
+	}
+
+	a, _ := newTestBot(t, configs[0])
+	b, _ := newTestBot(t, configs[1])
+
+	// Each bot keeps its own store and file
+	a.respondedUsers.MarkResponded(42)
+	if b.respondedUsers.HasResponded(42) {
+		t.Error("responding as one account marked the user for the other")
+	}
+	if a.config().RespondedUsersFile == b.config().RespondedUsersFile {
+		t.Error("accounts share a responded users file")
+	}
+
+	// Each bot answers with its own rules
+	if got, _ := a.engine.Load().Match("what is the price?", false); got != "From $10." {
+		t.Errorf("account a price reply = %q, want the shared rule", got)
+	}
+	if got, _ := b.engine.Load().Match("what is the price?", false); got != "Thanks!" {
+		t.Errorf("account b price reply = %q, want the default, its rules replace the shared ones", got)
+	}
+	if got, _ := b.engine.Load().Match("what are your hours?", false); got != "We open at 9." {
+		t.Errorf("account b hours reply = %q, want its own rule", got)
+	}
+}
+
+func TestAccountsMustNotShareState(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, `{
+		"check_interval_seconds": 60,
+		"log_file": "`+filepath.Join(dir, "bot.log")+`",
+		"default_response": "Thanks!",
+		"responded_users_file": "`+filepath.Join(dir, "responded.json")+`",
+		"accounts": [
+			{"username": "a", "password": "x", "config_path": "`+filepath.Join(dir, "a.json")+`"},
+			{"username": "b", "password": "x", "config_path": "`+filepath.Join(dir, "b.json")+`"}
+		]
+	}`)
+
+	_, err := loadAccounts(path)
+	if err == nil || !strings.Contains(err.Error(), `accounts 1 and 2 share responded_users_file`) {
+		t.Errorf("loadAccounts error = %v, want the shared responded_users_file reported", err)
+	}
+}
+
+// singleAccountConfig describes one account keeping its files in dir, with
+// the given response rules
+func singleAccountConfig(dir, rules string) string {
+	return `{
+		"username": "shop",
+		"password": "secret",
+		"config_path": "` + filepath.Join(dir, "session.json") + `",
+		"check_interval_seconds": 60,
+		"log_file": "` + filepath.Join(dir, "bot.log") + `",
+		"log_level": "error",
+		"responded_users_file": "` + filepath.Join(dir, "responded.json") + `",
+		"default_response": "Thanks!",
+		"response_rules": ` + rules + `
+	}`
+}
+
+func TestReloadAppliesNewRulesAndRejectsInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, singleAccountConfig(dir, `{"price": "From $10."}`))
+	configs, err := loadAccounts(path)
+	if err != nil {
+		t.Fatalf("loadAccounts: %v", err)
+	}
+	bot, _ := newTestBot(t, configs[0])
+
+	reply := func(text string) string {
+		got, _ := bot.chooseResponse(textItem("i1", 42, text, time.Now()), false)
+		return got
+	}
+	if got := reply("what's the price?"); got != "From $10." {
+		t.Fatalf("before reload got %q", got)
+	}
+
+	writeConfigFile(t, dir, singleAccountConfig(dir, `{"price": "Now from $8!", "hours": "Open 9 to 5."}`))
+	if err := bot.ReloadConfig(path); err != nil {
+		t.Fatalf("ReloadConfig: %v", err)
+	}
+	if got := reply("what's the price?"); got != "Now from $8!" {
+		t.Errorf("changed rule got %q after reload", got)
+	}
+	if got := reply("what are your hours?"); got != "Open 9 to 5." {
+		t.Errorf("new rule got %q after reload", got)
+	}
+
+	// A broken file keeps the rules that are running
+	writeConfigFile(t, dir, strings.Replace(singleAccountConfig(dir, `{"price": "Free!"}`), `"check_interval_seconds": 60`, `"check_interval_seconds": 0`, 1))
+	err = bot.ReloadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "check_interval_seconds must be positive") {
+		t.Errorf("ReloadConfig = %v, want the validation problem", err)
+	}
+	writeConfigFile(t, dir, `{"response_rules": `)
+	if err := bot.ReloadConfig(path); err == nil {
+		t.Error("ReloadConfig accepted malformed JSON")
+	}
+	if got := reply("what's the price?"); got != "Now from $8!" {
+		t.Errorf("after rejected reloads got %q, want the last valid rule", got)
+	}
+}
+
+func TestReloadRequiresTheSameAccount(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, singleAccountConfig(dir, `{}`))
+	bot, _ := newTestBot(t, newTestConfig(t))
+
+	if err := bot.ReloadConfig(path); err == nil || !strings.Contains(err.Error(), "no account with config_path") {
+		t.Errorf("ReloadConfig = %v, want the account to be missing", err)
+	}
+}
+
+func TestReloadedCheckIntervalIsUsed(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, singleAccountConfig(dir, `{}`))
+	configs, err := loadAccounts(path)
+	if err != nil {
+		t.Fatalf("loadAccounts: %v", err)
+	}
+	configs[0].CheckInterval = 300
+	bot, _ := newTestBot(t, configs[0])
+	bot.checkInterval.Store(300)
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	// The interval in use drives the health window until the ticker follows
+	writeConfigFile(t, dir, strings.Replace(singleAccountConfig(dir, `{}`), `"check_interval_seconds": 60`, `"check_interval_seconds": 1`, 1))
+	if err := bot.ReloadConfig(path); err != nil {
+		t.Fatalf("ReloadConfig: %v", err)
+	}
+	if got := bot.healthWindow(); got != 15*time.Minute {
+		t.Errorf("health window before the ticker follows = %s, want 15m", got)
+	}
+
+	bot.followCheckInterval(ticker)
+	if got := bot.checkInterval.Load(); got != 1 {
+		t.Fatalf("check interval = %d, want the reloaded 1", got)
+	}
+	select {
+	case <-ticker.C:
+	case <-time.After(3 * time.Second):
+		t.Fatal("ticker not reset to the reloaded interval")
+	}
+	if got := bot.healthWindow(); got != minHealthWindow {
+		t.Errorf("health window = %s, want %s", got, minHealthWindow)
+	}
+}
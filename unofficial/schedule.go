@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Davincible/goinsta"
+)
+
+// Schedule defines the working hours during which the team replies itself
+type Schedule struct {
+	// Timezone is an IANA zone name such as "America/New_York", defaulting to UTC
+	Timezone string `json:"timezone"`
+	// Days lists working days as "mon".."sun", defaulting to every day
+	Days []string `json:"days"`
+	// Start and End bound working hours as "15:04"; End may be before Start
+	// for windows that span midnight
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// InHoursResponse is sent instead of the normal reply during working
+	// hours. If empty, no auto-reply is sent during working hours.
+	InHoursResponse string `json:"in_hours_response"`
+}
+
+// InHours reports whether t falls within working hours
+func (s *Schedule) InHours(t time.Time) (bool, error) {
+	loc := time.UTC
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return false, fmt.Errorf("invalid schedule timezone %q: %w", s.Timezone, err)
+		}
+	}
+
+	start, err := parseClock(s.Start)
+	if err != nil {
+		return false, fmt.Errorf("invalid schedule start: %w", err)
+	}
+	end, err := parseClock(s.End)
+	if err != nil {
+		return false, fmt.Errorf("invalid schedule end: %w", err)
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	// For overnight windows the hours after midnight belong to the previous day
+	day := local.Weekday()
+	var within bool
+	if start <= end {
+		within = minute >= start && minute < end
+	} else {
+		within = minute >= start || minute < end
+		if minute < end {
+			day = (day + 6) % 7
+		}
+	}
+	if !within {
+		return false, nil
+	}
+
+	if len(s.Days) == 0 {
+		return true, nil
+	}
+	for _, d := range s.Days {
+		if strings.EqualFold(d, day.String()[:3]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseClock converts "15:04" into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time %q must be formatted as HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// receivedAt returns when item was received, using now if unknown
+func receivedAt(item *goinsta.InboxItem) time.Time {
+	if item.Timestamp == 0 {
+		return time.Now()
+	}
+	// Instagram timestamps are in microseconds
+	return time.UnixMicro(item.Timestamp)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	// Keep the timezone tests independent of the host's zone database
+	_ "time/tzdata"
+)
+
+func TestInHours(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weekdays := &Schedule{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}
+	eastern := &Schedule{Timezone: "America/New_York", Start: "09:00", End: "17:00"}
+	overnight := &Schedule{Days: []string{"fri"}, Start: "22:00", End: "06:00"}
+
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		at       time.Time
+		want     bool
+	}{
+		// Wednesday 6 March 2024
+		{"before start", weekdays, time.Date(2024, 3, 6, 8, 59, 0, 0, time.UTC), false},
+		{"at start", weekdays, time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC), true},
+		{"last minute", weekdays, time.Date(2024, 3, 6, 16, 59, 59, 0, time.UTC), true},
+		{"at end", weekdays, time.Date(2024, 3, 6, 17, 0, 0, 0, time.UTC), false},
+		{"weekend", weekdays, time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), false},
+
+		// 13:30 UTC is 08:30 in New York before DST starts on 10 March
+		{"timezone before start", eastern, time.Date(2024, 3, 6, 13, 30, 0, 0, time.UTC), false},
+		{"timezone at start", eastern, time.Date(2024, 3, 6, 14, 0, 0, 0, time.UTC), true},
+		{"timezone after DST", eastern, time.Date(2024, 3, 11, 13, 30, 0, 0, time.UTC), true},
+		{"timezone local time", eastern, time.Date(2024, 3, 6, 16, 59, 0, 0, newYork), true},
+		{"timezone end", eastern, time.Date(2024, 3, 6, 22, 0, 0, 0, time.UTC), false},
+
+		// Friday night into Saturday morning
+		{"overnight start", overnight, time.Date(2024, 3, 8, 22, 0, 0, 0, time.UTC), true},
+		{"overnight after midnight", overnight, time.Date(2024, 3, 9, 5, 59, 0, 0, time.UTC), true},
+		{"overnight end", overnight, time.Date(2024, 3, 9, 6, 0, 0, 0, time.UTC), false},
+		{"overnight other day", overnight, time.Date(2024, 3, 8, 5, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.InHours(tt.at)
+			if err != nil {
+				t.Fatalf("InHours: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("InHours(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInHoursRejectsInvalidSchedule(t *testing.T) {
+	for _, schedule := range []*Schedule{
+		{Timezone: "Mars/Olympus", Start: "09:00", End: "17:00"},
+		{Start: "9am", End: "17:00"},
+		{Start: "09:00", End: "25:00"},
+	} {
+		if _, err := schedule.InHours(time.Now()); err == nil {
+			t.Errorf("no error for %+v", schedule)
+		}
+	}
+}
+
+func TestScheduleGatesReplies(t *testing.T) {
+	// Wednesday 6 March 2024 in New York
+	opening := time.Date(2024, 3, 6, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		inHoursResponse string
+		at              time.Time
+		want            []string
+	}{
+		{"outside hours", "", opening.Add(-time.Minute), []string{"Thanks for your message!"}},
+		{"in hours without response", "", opening, nil},
+		{"in hours with response", "We'll answer shortly.", opening, []string{"We'll answer shortly."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(t)
+			config.Schedule = &Schedule{
+				Timezone:        "America/New_York",
+				Start:           "09:00",
+				End:             "17:00",
+				InHoursResponse: tt.inHoursResponse,
+			}
+			bot, sender := newTestBot(t, config)
+
+			item := textItem("i1", 42, "hello", tt.at)
+			bot.respondToMessage(newTestConversation("t1", 42, item), item, false)
+
+			if got := sender.Texts(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
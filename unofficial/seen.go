@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// SeenUsers tracks when each user was first seen messaging the account
+type SeenUsers struct {
+	Users map[int64]time.Time `json:"users"`
+	mu    sync.Mutex
+}
+
+// NewSeenUsers initializes the seen users tracker
+func NewSeenUsers(filepath string) (*SeenUsers, error) {
+	su := &SeenUsers{
+		Users: make(map[int64]time.Time),
+	}
+
+	// Load previously seen users if file exists
+	if _, err := os.Stat(filepath); err == nil {
+		data, err := os.ReadFile(filepath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading seen users file: %w", err)
+		}
+
+		var loadedUsers map[int64]time.Time
+		if err := json.Unmarshal(data, &loadedUsers); err != nil {
+			return nil, fmt.Errorf("error unmarshaling seen users: %w", err)
+		}
+		su.Users = loadedUsers
+	}
+
+	return su, nil
+}
+
+// MarkSeen records a message from the user received at the given time,
+// returning whether the user had messaged before it. Seeing the same
+// message again doesn't make the user a returning one.
+func (su *SeenUsers) MarkSeen(userID int64, at time.Time) bool {
+	su.mu.Lock()
+	defer su.mu.Unlock()
+	if first, exists := su.Users[userID]; exists && !at.Before(first) {
+		return first.Before(at)
+	}
+	su.Users[userID] = at
+	return false
+}
+
+// Save persists the seen users data to file
+func (su *SeenUsers) Save(filepath string) error {
+	su.mu.Lock()
+	defer su.mu.Unlock()
+
+	data, err := json.MarshalIndent(su.Users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling seen users: %w", err)
+	}
+
+	if err := os.WriteFile(filepath, data, 0644); err != nil {
+		return fmt.Errorf("error writing seen users file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+func TestMarkSeen(t *testing.T) {
+	su := &SeenUsers{Users: make(map[int64]time.Time)}
+	first := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	if su.MarkSeen(42, first) {
+		t.Error("first message reported as returning")
+	}
+	if su.MarkSeen(42, first) {
+		t.Error("same message seen again reported as returning")
+	}
+	if !su.MarkSeen(42, first.Add(time.Hour)) {
+		t.Error("later message not reported as returning")
+	}
+	if su.MarkSeen(7, first.Add(time.Hour)) {
+		t.Error("other user reported as returning")
+	}
+}
+
+// returningConfig answers returning users with a different rule
+func returningConfig(t *testing.T) *Configuration {
+	config := newTestConfig(t)
+	config.SeenUsersFile = filepath.Join(t.TempDir(), "seen_users.json")
+	config.ResponseRules = map[string]responder.Response{
+		"hello": {Text: "Welcome back!", RequireReturningUser: true},
+	}
+	return config
+}
+
+func TestFirstTimeUserStaysNewWhenReplyIsRetried(t *testing.T) {
+	bot, sender := newTestBot(t, returningConfig(t))
+	failing := true
+	sender.fail = func(string) error {
+		if failing {
+			return errors.New("send failed")
+		}
+		return nil
+	}
+
+	conv := newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now()))
+	bot.processConversation(conv)
+	failing = false
+	bot.processConversation(conv)
+
+	want := []string{"Thanks for your message!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
+
+func TestReturningUserGetsReturningRule(t *testing.T) {
+	bot, sender := newTestBot(t, returningConfig(t))
+	bot.seenUsers.MarkSeen(42, time.Now().Add(-48*time.Hour))
+
+	conv := newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now()))
+	bot.processConversation(conv)
+
+	want := []string{"Welcome back!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
+
+func TestEarlierMessageInThreadMakesUserReturning(t *testing.T) {
+	bot, sender := newTestBot(t, returningConfig(t))
+
+	// The user wrote before the bot was deployed and got no auto-reply
+	conv := newTestConversation("t1", 42,
+		textItem("i2", 42, "hello again", time.Now()),
+		textItem("i1", 42, "do you ship abroad?", time.Now().Add(-30*24*time.Hour)),
+	)
+	bot.processConversation(conv)
+
+	want := []string{"Welcome back!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
+
+func TestFirstTimeUserSkipsReturningRule(t *testing.T) {
+	bot, sender := newTestBot(t, returningConfig(t))
+
+	bot.processConversation(newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now())))
+
+	want := []string{"Thanks for your message!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
+
+func TestReturningUserSurvivesRestart(t *testing.T) {
+	config := returningConfig(t)
+
+	// Answered during working hours, so no auto-reply was recorded
+	first, _ := newTestBot(t, config)
+	first.seenUsers.MarkSeen(42, time.Now().Add(-time.Hour))
+	if err := first.seenUsers.Save(config.SeenUsersFile); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	bot, sender := newTestBot(t, config)
+	bot.processConversation(newTestConversation("t2", 42, textItem("i2", 42, "hello", time.Now())))
+
+	want := []string{"Welcome back!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
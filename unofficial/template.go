@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Davincible/goinsta"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+// newResponseData builds the template variables for the sender of item
+func newResponseData(conv *goinsta.Conversation, item *goinsta.InboxItem) responder.ResponseData {
+	data := responder.ResponseData{Now: time.Now()}
+	for _, user := range conv.Users {
+		if user.ID == item.UserID {
+			data.Username = user.Username
+			data.FullName = user.FullName
+			break
+		}
+	}
+	return data
+}
+
+// newEngine creates the response engine for a configuration
+func (bot *InstagramBot) newEngine(config *Configuration) *responder.ResponseEngine {
+	return responder.NewResponseEngine(config.Config).WithLogger(bot.logger)
+}
+
+// renderResponse fills in the sender details of a response template
+func (bot *InstagramBot) renderResponse(response string, data responder.ResponseData) string {
+	return bot.engine.Load().Render(response, data)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// typingDelayPerChar approximates how long a person takes to type one character
+	typingDelayPerChar = 50 * time.Millisecond
+	// maxTypingDelay caps the simulated typing time for long responses
+	maxTypingDelay = 8 * time.Second
+)
+
+// Safe-mode limits applied to fresh accounts
+const (
+	safeModeSendRatePerMinute = 2
+	safeModeCheckInterval     = 300
+	safeModeDailyReplyBudget  = 50
+)
+
+// ApplySafeMode clamps aggressive settings to conservative values when
+// SafeMode is enabled. Settings named in SafeModeAllow by their JSON key
+// keep their configured values.
+func (c *Configuration) ApplySafeMode() {
+	if !c.SafeMode {
+		return
+	}
+
+	allowed := make(map[string]bool, len(c.SafeModeAllow))
+	for _, name := range c.SafeModeAllow {
+		allowed[name] = true
+	}
+
+	if !allowed["send_rate_per_minute"] && (c.SendRatePerMinute <= 0 || c.SendRatePerMinute > safeModeSendRatePerMinute) {
+		c.SendRatePerMinute = safeModeSendRatePerMinute
+	}
+	if !allowed["check_interval_seconds"] && c.CheckInterval < safeModeCheckInterval {
+		c.CheckInterval = safeModeCheckInterval
+	}
+	if !allowed["daily_reply_budget"] && (c.DailyReplyBudget <= 0 || c.DailyReplyBudget > safeModeDailyReplyBudget) {
+		c.DailyReplyBudget = safeModeDailyReplyBudget
+	}
+	if !allowed["simulate_typing"] {
+		c.SimulateTyping = true
+	}
+}
+
+// sendThrottle paces outgoing messages and enforces the daily reply budget
+type sendThrottle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	budget   int
+	typing   bool
+	next     time.Time
+	day      string
+	sent     int
+}
+
+// newSendThrottle creates a throttle from the configured limits
+func newSendThrottle(config *Configuration) *sendThrottle {
+	t := &sendThrottle{}
+	t.Update(config)
+	return t
+}
+
+// Update applies new limits, keeping today's reply count
+func (t *sendThrottle) Update(config *Configuration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.budget = config.DailyReplyBudget
+	t.typing = config.SimulateTyping
+	t.interval = 0
+	if config.SendRatePerMinute > 0 {
+		t.interval = time.Minute / time.Duration(config.SendRatePerMinute)
+	}
+}
+
+// Wait blocks until the next send slot is available and, if enabled,
+// simulates the time it takes to type text. It takes one reply from
+// today's budget, which Refund gives back if the message doesn't count,
+// and returns false without waiting when the budget is used up.
+func (t *sendThrottle) Wait(text string) bool {
+	t.mu.Lock()
+	now := time.Now()
+
+	// Reset the budget at the start of each day
+	if day := now.Format("2006-01-02"); day != t.day {
+		t.day = day
+		t.sent = 0
+	}
+	if t.budget > 0 && t.sent >= t.budget {
+		t.mu.Unlock()
+		return false
+	}
+	t.sent++
+
+	// Reserve the next free slot so concurrent senders queue up behind each other
+	slot := now
+	if t.next.After(slot) {
+		slot = t.next
+	}
+	t.next = slot.Add(t.interval)
+	typing := t.typing
+	t.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+
+	if typing {
+		delay := time.Duration(len([]rune(text))) * typingDelayPerChar
+		if delay > maxTypingDelay {
+			delay = maxTypingDelay
+		}
+		time.Sleep(delay)
+	}
+
+	return true
+}
+
+// Refund gives back a reply taken by Wait, for messages that failed to
+// send or don't count against the budget
+func (t *sendThrottle) Refund() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sent > 0 {
+		t.sent--
+	}
+}
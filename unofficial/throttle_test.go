@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApplySafeModeClampsSettings(t *testing.T) {
+	config := &Configuration{
+		SafeMode:          true,
+		SendRatePerMinute: 30,
+		CheckInterval:     10,
+		DailyReplyBudget:  0,
+	}
+	config.ApplySafeMode()
+
+	if config.SendRatePerMinute != safeModeSendRatePerMinute {
+		t.Errorf("send rate = %d, want %d", config.SendRatePerMinute, safeModeSendRatePerMinute)
+	}
+	if config.CheckInterval != safeModeCheckInterval {
+		t.Errorf("check interval = %d, want %d", config.CheckInterval, safeModeCheckInterval)
+	}
+	if config.DailyReplyBudget != safeModeDailyReplyBudget {
+		t.Errorf("daily budget = %d, want %d", config.DailyReplyBudget, safeModeDailyReplyBudget)
+	}
+	if !config.SimulateTyping {
+		t.Error("typing simulation not enabled")
+	}
+}
+
+func TestApplySafeModeKeepsAllowedAndConservativeSettings(t *testing.T) {
+	config := &Configuration{
+		SafeMode:          true,
+		SafeModeAllow:     []string{"check_interval_seconds", "simulate_typing"},
+		SendRatePerMinute: 1,
+		CheckInterval:     10,
+		DailyReplyBudget:  20,
+	}
+	config.ApplySafeMode()
+
+	if config.SendRatePerMinute != 1 || config.DailyReplyBudget != 20 {
+		t.Errorf("conservative settings changed: rate %d, budget %d", config.SendRatePerMinute, config.DailyReplyBudget)
+	}
+	if config.CheckInterval != 10 {
+		t.Errorf("allowed check interval clamped to %d", config.CheckInterval)
+	}
+	if config.SimulateTyping {
+		t.Error("allowed typing simulation enabled")
+	}
+}
+
+func TestApplySafeModeDisabled(t *testing.T) {
+	config := &Configuration{SendRatePerMinute: 30, CheckInterval: 10}
+	config.ApplySafeMode()
+	if config.SendRatePerMinute != 30 || config.CheckInterval != 10 {
+		t.Errorf("settings changed without safe mode: %+v", config)
+	}
+}
+
+func TestThrottleBudget(t *testing.T) {
+	throttle := newSendThrottle(&Configuration{DailyReplyBudget: 2})
+
+	if !throttle.Wait("a") || !throttle.Wait("b") {
+		t.Fatal("replies within the budget refused")
+	}
+	if throttle.Wait("c") {
+		t.Fatal("reply over the budget allowed")
+	}
+	throttle.Refund()
+	if !throttle.Wait("c") {
+		t.Error("refunded reply not available again")
+	}
+}
+
+func TestFailedSendsAndAcksDontUseBudget(t *testing.T) {
+	config := newTestConfig(t)
+	config.DailyReplyBudget = 1
+	config.TwoPhaseReply = &TwoPhaseReply{Enabled: true, AckText: "One moment..."}
+	bot, sender := newTestBot(t, config)
+
+	failing := true
+	sender.fail = func(string) error {
+		if failing {
+			return errors.New("send failed")
+		}
+		return nil
+	}
+	item := textItem("i1", 42, "hello", time.Now())
+	conv := newTestConversation("t1", 42, item)
+	bot.respondToMessage(conv, item, false)
+
+	// The failure gave the reply back, and the acknowledgement is free
+	failing = false
+	bot.respondToMessage(conv, item, false)
+	if !bot.respondedUsers.HasResponded(42) {
+		t.Fatalf("reply not sent after a failed attempt, sent %q", sender.Texts())
+	}
+
+	// The one reply of the day is now used up
+	other := textItem("i2", 43, "hello", time.Now())
+	bot.respondToMessage(newTestConversation("t2", 43, other), other, false)
+	if bot.respondedUsers.HasResponded(43) {
+		t.Error("reply over the daily budget sent")
+	}
+}
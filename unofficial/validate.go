@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// weekdays are the day names accepted in a schedule
+var weekdays = map[string]bool{
+	"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true,
+}
+
+// Validate checks the configuration, returning a *ValidationError that
+// lists every problem found
+func (c *Configuration) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// A saved session can stand in for credentials
+	hasCredentials := c.Username != "" && c.Password != ""
+	hasSession := false
+	if c.ConfigPath != "" {
+		_, err := os.Stat(c.ConfigPath)
+		hasSession = err == nil
+	}
+	if !hasCredentials && !hasSession {
+		problem("username and password are required when no session exists at config_path %q", c.ConfigPath)
+	}
+	if c.ConfigPath == "" {
+		problem("config_path is required to save the session")
+	}
+
+	if c.CheckInterval <= 0 {
+		problem("check_interval_seconds must be positive, got %d", c.CheckInterval)
+	}
+	// A localized default for the default language also covers every
+	// message the other languages don't
+	defaultLang := c.FallbackLanguage()
+	if c.DefaultResponse == "" && c.DefaultResponses[defaultLang] == "" {
+		problem("default_response is required unless default_responses has one for %q", defaultLang)
+	}
+
+	requireWritable := func(key, path string) {
+		if path == "" {
+			problem("%s is required", key)
+		} else if err := checkWritable(path); err != nil {
+			problem("%s %q is not writable: %v", key, path, err)
+		}
+	}
+	requireWritable("log_file", c.LogFile)
+	requireWritable("responded_users_file", c.RespondedUsersFile)
+	// Without the file the bot introduces itself again after every restart
+	if c.JoinedThreadsFile != "" {
+		requireWritable("joined_threads_file", c.JoinedThreadsFile)
+	} else if c.GroupJoinResponse != "" {
+		problem("joined_threads_file is required when group_join_response is set")
+	}
+	if c.PendingRepliesFile != "" {
+		requireWritable("pending_replies_file", c.PendingRepliesFile)
+	}
+	// Without the file every user is new again after a restart
+	if c.SeenUsersFile != "" {
+		requireWritable("seen_users_file", c.SeenUsersFile)
+	} else if rule := returningUserRule(c.ResponseRules); rule != "" {
+		problem("seen_users_file is required when response rule %q sets require_returning_user", rule)
+	}
+
+	if c.SendRatePerMinute < 0 {
+		problem("send_rate_per_minute must not be negative, got %d", c.SendRatePerMinute)
+	}
+	if c.DailyReplyBudget < 0 {
+		problem("daily_reply_budget must not be negative, got %d", c.DailyReplyBudget)
+	}
+	if c.RespondedUsersRetentionHours < 0 {
+		problem("responded_users_retention_hours must not be negative, got %d", c.RespondedUsersRetentionHours)
+	}
+	if c.PruneEveryChecks < 0 {
+		problem("prune_every_checks must not be negative, got %d", c.PruneEveryChecks)
+	}
+	if c.Concurrency < 0 {
+		problem("concurrency must not be negative, got %d", c.Concurrency)
+	}
+	if c.MetricsPort < 0 || c.MetricsPort > 65535 {
+		problem("metrics_port must be between 0 and 65535, got %d", c.MetricsPort)
+	}
+
+	switch c.VanishModePolicy {
+	case "", VanishModeSkip, VanishModeReplyInVanish, VanishModeReplyNormal:
+	default:
+		problem("vanish_mode_policy must be %q, %q or %q, got %q", VanishModeSkip, VanishModeReplyInVanish, VanishModeReplyNormal, c.VanishModePolicy)
+	}
+
+	switch c.TwoFactorCodeSource {
+	case "", CodeSourceStdin:
+	case CodeSourceFile:
+		if c.TwoFactorCodeFile == "" {
+			problem("two_factor_code_file is required when two_factor_code_source is %q", CodeSourceFile)
+		}
+	default:
+		problem("two_factor_code_source must be %q or %q, got %q", CodeSourceStdin, CodeSourceFile, c.TwoFactorCodeSource)
+	}
+
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		problem("log_level must be one of debug, info, warn or error, got %q", c.LogLevel)
+	}
+	switch strings.ToLower(c.LogFormat) {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		problem("log_format must be %q or %q, got %q", LogFormatText, LogFormatJSON, c.LogFormat)
+	}
+
+	if c.LoggedOutCooldownMinutes < 0 {
+		problem("logged_out_cooldown_minutes must not be negative, got %d", c.LoggedOutCooldownMinutes)
+	}
+	if c.AlertURL != "" {
+		if u, err := url.Parse(c.AlertURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem("alert_url must be an http or https URL, got %q", c.AlertURL)
+		}
+	}
+
+	if c.UnmatchedForwardURL != "" {
+		if u, err := url.Parse(c.UnmatchedForwardURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem("unmatched_forward_url must be an http or https URL, got %q", c.UnmatchedForwardURL)
+		}
+	} else if c.SuppressDefaultOnForward {
+		problem("suppress_default_on_forward requires unmatched_forward_url")
+	}
+
+	if c.TwoPhaseReply != nil && c.TwoPhaseReply.Enabled && c.TwoPhaseReply.AckText == "" {
+		problem("two_phase_reply.ack_text is required when two_phase_reply is enabled")
+	}
+
+	if c.Schedule != nil {
+		if c.Schedule.Timezone != "" {
+			if _, err := time.LoadLocation(c.Schedule.Timezone); err != nil {
+				problem("schedule timezone %q is unknown", c.Schedule.Timezone)
+			}
+		}
+		if _, err := parseClock(c.Schedule.Start); err != nil {
+			problem("schedule start: %v", err)
+		}
+		if _, err := parseClock(c.Schedule.End); err != nil {
+			problem("schedule end: %v", err)
+		}
+		for _, day := range c.Schedule.Days {
+			if !weekdays[strings.ToLower(day)] {
+				problem("schedule day %q must be one of mon, tue, wed, thu, fri, sat, sun", day)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// returningUserRule returns the first rule, in sorted order, that only
+// matches returning users, or "" if none does
+func returningUserRule(rules map[string]responder.Response) string {
+	var patterns []string
+	for pattern, response := range rules {
+		if response.RequireReturningUser {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return ""
+	}
+	sort.Strings(patterns)
+	return patterns[0]
+}
+
+// validateAccounts validates each account and checks that no two accounts
+// share a session or state file, returning a *ValidationError that lists
+// every problem found
+func validateAccounts(configs []*Configuration) error {
+	var problems []string
+	for i, c := range configs {
+		err := c.Validate()
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) {
+			continue
+		}
+		for _, p := range invalid.Problems {
+			if len(configs) > 1 {
+				p = fmt.Sprintf("account %d: %s", i+1, p)
+			}
+			problems = append(problems, p)
+		}
+	}
+
+	// Shared files would let one account's state overwrite another's
+	seen := make(map[string]int)
+	unique := func(i int, key, value string) {
+		if value == "" {
+			return
+		}
+		if first, ok := seen[key+"="+value]; ok {
+			problems = append(problems, fmt.Sprintf("accounts %d and %d share %s %q", first+1, i+1, key, value))
+			return
+		}
+		seen[key+"="+value] = i
+	}
+	for i, c := range configs {
+		unique(i, "config_path", c.ConfigPath)
+		unique(i, "responded_users_file", c.RespondedUsersFile)
+		unique(i, "joined_threads_file", c.JoinedThreadsFile)
+		unique(i, "pending_replies_file", c.PendingRepliesFile)
+		unique(i, "seen_users_file", c.SeenUsersFile)
+		unique(i, "two_factor_code_file", c.TwoFactorCodeFile)
+		if c.MetricsPort != 0 {
+			unique(i, "metrics_port", fmt.Sprint(c.MetricsPort))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// checkWritable verifies that path can be written, creating and removing
+// a probe file when it doesn't exist yet
+func checkWritable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err == nil {
+		return f.Close()
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(path), ".write-check-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
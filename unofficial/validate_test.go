@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	config := newTestConfig(t)
+	config.Schedule = &Schedule{Timezone: "Europe/Berlin", Days: []string{"Mon", "fri"}, Start: "09:00", End: "17:00"}
+	config.AlertURL = "https://alerts.example.com/hook"
+	config.LogFormat = "JSON"
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateReportsEachProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Configuration)
+		want   string
+	}{
+		{"no credentials", func(c *Configuration) { c.Password = "" },
+			"username and password are required when no session exists"},
+		{"no config path", func(c *Configuration) { c.ConfigPath = "" },
+			"config_path is required to save the session"},
+		{"check interval", func(c *Configuration) { c.CheckInterval = 0 },
+			"check_interval_seconds must be positive, got 0"},
+		{"no default response", func(c *Configuration) { c.DefaultResponse = "" },
+			`default_response is required unless default_responses has one for "en"`},
+		{"unwritable log file", func(c *Configuration) { c.LogFile = filepath.Join(t.TempDir(), "missing", "bot.log") },
+			"log_file"},
+		{"group join without state file", func(c *Configuration) { c.GroupJoinResponse = "Hi all!" },
+			"joined_threads_file is required when group_join_response is set"},
+		{"returning rule without seen users file", func(c *Configuration) {
+			c.ResponseRules = map[string]responder.Response{"hello": {Text: "Welcome back!", RequireReturningUser: true}}
+		}, `seen_users_file is required when response rule "hello" sets require_returning_user`},
+		{"negative budget", func(c *Configuration) { c.DailyReplyBudget = -1 },
+			"daily_reply_budget must not be negative, got -1"},
+		{"metrics port", func(c *Configuration) { c.MetricsPort = 70000 },
+			"metrics_port must be between 0 and 65535, got 70000"},
+		{"vanish policy", func(c *Configuration) { c.VanishModePolicy = "reply-later" },
+			`vanish_mode_policy must be "skip", "reply-in-vanish" or "reply-normal", got "reply-later"`},
+		{"code file", func(c *Configuration) { c.TwoFactorCodeSource = CodeSourceFile },
+			`two_factor_code_file is required when two_factor_code_source is "file"`},
+		{"log level", func(c *Configuration) { c.LogLevel = "chatty" },
+			`log_level must be one of debug, info, warn or error, got "chatty"`},
+		{"alert url", func(c *Configuration) { c.AlertURL = "alerts.example.com" },
+			`alert_url must be an http or https URL, got "alerts.example.com"`},
+		{"suppress without forward", func(c *Configuration) { c.SuppressDefaultOnForward = true },
+			"suppress_default_on_forward requires unmatched_forward_url"},
+		{"two-phase without ack", func(c *Configuration) { c.TwoPhaseReply = &TwoPhaseReply{Enabled: true} },
+			"two_phase_reply.ack_text is required when two_phase_reply is enabled"},
+		{"schedule timezone", func(c *Configuration) { c.Schedule = &Schedule{Timezone: "Mars/Olympus", Start: "09:00", End: "17:00"} },
+			`schedule timezone "Mars/Olympus" is unknown`},
+		{"schedule day", func(c *Configuration) {
+			c.Schedule = &Schedule{Days: []string{"someday"}, Start: "09:00", End: "17:00"}
+		},
+			`schedule day "someday" must be one of mon, tue, wed, thu, fri, sat, sun`},
+		{"schedule time", func(c *Configuration) { c.Schedule = &Schedule{Start: "9am", End: "17:00"} },
+			`schedule start: time "9am" must be formatted as HH:MM`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(t)
+			tt.modify(config)
+
+			var invalid *ValidationError
+			if err := config.Validate(); !errors.As(err, &invalid) {
+				t.Fatalf("Validate = %v, want a *ValidationError", err)
+			}
+			if len(invalid.Problems) != 1 || !strings.Contains(invalid.Problems[0], tt.want) {
+				t.Errorf("problems = %q, want one containing %q", invalid.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	config := newTestConfig(t)
+	config.CheckInterval = -5
+	config.Concurrency = -1
+	config.LogFormat = "xml"
+
+	var invalid *ValidationError
+	if err := config.Validate(); !errors.As(err, &invalid) {
+		t.Fatalf("Validate = %v, want a *ValidationError", err)
+	}
+	want := []string{
+		"check_interval_seconds must be positive, got -5",
+		"concurrency must not be negative, got -1",
+		`log_format must be "text" or "json", got "xml"`,
+	}
+	if !reflect.DeepEqual(invalid.Problems, want) {
+		t.Errorf("problems = %q, want %q", invalid.Problems, want)
+	}
+	if !strings.Contains(invalid.Error(), "\n  - concurrency must not be negative") {
+		t.Errorf("Error() = %q, want one problem per line", invalid.Error())
+	}
+}
+
+func TestValidateAccountsRejectsSharedFiles(t *testing.T) {
+	a, b := newTestConfig(t), newTestConfig(t)
+	b.RespondedUsersFile = a.RespondedUsersFile
+	b.CheckInterval = 0
+
+	var invalid *ValidationError
+	if err := validateAccounts([]*Configuration{a, b}); !errors.As(err, &invalid) {
+		t.Fatalf("validateAccounts = %v, want a *ValidationError", err)
+	}
+	want := []string{
+		"account 2: check_interval_seconds must be positive, got 0",
+		`accounts 1 and 2 share responded_users_file "` + a.RespondedUsersFile + `"`,
+	}
+	if !reflect.DeepEqual(invalid.Problems, want) {
+		t.Errorf("problems = %q, want %q", invalid.Problems, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/Davincible/goinsta"
+)
+
+// Vanish mode policies
+const (
+	// VanishModeSkip never replies to vanish-mode messages
+	VanishModeSkip = "skip"
+	// VanishModeReplyInVanish, the default, replies inside the vanish-mode
+	// thread, so the reply disappears together with the rest of the
+	// session
+	VanishModeReplyInVanish = "reply-in-vanish"
+	// VanishModeReplyNormal holds the reply until the thread is back in
+	// normal mode, since the API can't reply outside of vanish mode. Held
+	// replies are kept in memory only and lost on restart.
+	VanishModeReplyNormal = "reply-normal"
+)
+
+// isVanishMode reports whether item was sent in a vanish-mode thread
+func isVanishMode(conv *goinsta.Conversation, item *goinsta.InboxItem) bool {
+	return conv.ShhModeEnabled || item.IsShhMode
+}
+
+// allowVanishReply applies the configured vanish mode policy, reporting
+// whether the bot may reply to item now
+func (bot *InstagramBot) allowVanishReply(conv *goinsta.Conversation, item *goinsta.InboxItem) bool {
+	if !isVanishMode(conv, item) {
+		return true
+	}
+
+	switch bot.config().VanishModePolicy {
+	case VanishModeSkip:
+		bot.logger.Info("Skipping vanish-mode message", "conversation_id", conv.ID, "user_id", item.UserID)
+		return false
+	case VanishModeReplyNormal:
+		if conv.ShhModeEnabled {
+			bot.logger.Info("Holding reply until vanish mode ends", "conversation_id", conv.ID, "user_id", item.UserID)
+			bot.heldForVanish.Hold(conv.ID, item)
+			return false
+		}
+	}
+	return true
+}
+
+// heldMessages remembers, per thread, the vanish-mode message whose reply
+// waits for the thread to leave vanish mode
+type heldMessages struct {
+	mu    sync.Mutex
+	items map[string]*goinsta.InboxItem
+}
+
+// newHeldMessages creates an empty store
+func newHeldMessages() *heldMessages {
+	return &heldMessages{items: make(map[string]*goinsta.InboxItem)}
+}
+
+// Hold records item as the message to answer in threadID
+func (h *heldMessages) Hold(threadID string, item *goinsta.InboxItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.items[threadID] = item
+}
+
+// Take returns and forgets the message held for threadID, if any
+func (h *heldMessages) Take(threadID string) *goinsta.InboxItem {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	item := h.items[threadID]
+	delete(h.items, threadID)
+	return item
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"projects/instagram_replayer_bot/responder"
+)
+
+func TestVanishModePolicy(t *testing.T) {
+	tests := []struct {
+		policy    string
+		wantReply bool
+	}{
+		{"", true},
+		{VanishModeReplyInVanish, true},
+		{VanishModeSkip, false},
+		// The thread itself is no longer in vanish mode
+		{VanishModeReplyNormal, true},
+	}
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			config := newTestConfig(t)
+			config.VanishModePolicy = tt.policy
+			bot, sender := newTestBot(t, config)
+
+			item := textItem("i1", 42, "hello", time.Now())
+			item.IsShhMode = true
+			bot.processConversation(newTestConversation("t1", 42, item))
+
+			if replied := len(sender.Texts()) > 0; replied != tt.wantReply {
+				t.Errorf("replied = %v, want %v", replied, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestVanishModeSkipStillAnswersNormalMessages(t *testing.T) {
+	config := newTestConfig(t)
+	config.VanishModePolicy = VanishModeSkip
+	bot, sender := newTestBot(t, config)
+
+	bot.processConversation(newTestConversation("t1", 42, textItem("i1", 42, "hello", time.Now())))
+	if len(sender.Texts()) != 1 {
+		t.Errorf("sent %q, want a reply", sender.Texts())
+	}
+}
+
+func TestVanishModeReplyNormalWaitsForNormalMode(t *testing.T) {
+	config := newTestConfig(t)
+	config.VanishModePolicy = VanishModeReplyNormal
+	bot, sender := newTestBot(t, config)
+
+	item := textItem("i1", 42, "hello", time.Now())
+	item.IsShhMode = true
+	vanishing := newTestConversation("t1", 42, item)
+	vanishing.ShhModeEnabled = true
+	bot.processConversation(vanishing)
+	bot.processConversation(vanishing)
+	if got := sender.Texts(); len(got) != 0 {
+		t.Fatalf("sent %q while the thread is in vanish mode", got)
+	}
+
+	// Leaving vanish mode clears its messages from the thread
+	bot.processConversation(newTestConversation("t1", 42))
+	want := []string{"Thanks for your message!"}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q after vanish mode ended, want %q", got, want)
+	}
+
+	bot.processConversation(newTestConversation("t1", 42))
+	if got := sender.Texts(); len(got) != 1 {
+		t.Errorf("sent %q, want the held message answered once", got)
+	}
+}
+
+func TestVanishModeReplyNormalPrefersNewerMessage(t *testing.T) {
+	config := newTestConfig(t)
+	config.ResponseRules = map[string]responder.Response{"price": {Text: "From $10."}}
+	config.VanishModePolicy = VanishModeReplyNormal
+	bot, sender := newTestBot(t, config)
+
+	item := textItem("i1", 42, "hello", time.Now().Add(-time.Minute))
+	item.IsShhMode = true
+	vanishing := newTestConversation("t1", 42, item)
+	vanishing.ShhModeEnabled = true
+	bot.processConversation(vanishing)
+
+	bot.processConversation(newTestConversation("t1", 42, textItem("i2", 42, "what's the price?", time.Now())))
+	want := []string{"From $10."}
+	if got := sender.Texts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestVanishModePolicyValidation(t *testing.T) {
+	config := newTestConfig(t)
+	config.VanishModePolicy = VanishModeReplyNormal
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want %q accepted", err, VanishModeReplyNormal)
+	}
+
+	config.VanishModePolicy = "reply-later"
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "vanish_mode_policy") {
+		t.Errorf("Validate() = %v, want a vanish_mode_policy problem", err)
+	}
+}